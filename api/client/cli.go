@@ -16,6 +16,7 @@ import (
 	"github.com/docker/docker/opts"
 	"github.com/docker/docker/pkg/term"
 	"github.com/docker/engine-api/client"
+	"github.com/docker/engine-api/client/transport"
 	"github.com/docker/go-connections/sockets"
 	"github.com/docker/go-connections/tlsconfig"
 )
@@ -152,10 +153,29 @@ func NewDockerCli(in io.ReadCloser, out, err io.Writer, clientFlags *cli.ClientF
 			return err
 		}
 
+		proxyAuth, err := client.ProxyAuthenticatorFromEnv()
+		if err != nil {
+			return err
+		}
+		signer, err := requestSignerFromEnv()
+		if err != nil {
+			return err
+		}
+		retryPolicy, err := client.RetryPolicyFromEnv()
+		if err != nil {
+			return err
+		}
+
 		client, err := client.NewClient(host, verStr, httpClient, customHeaders)
 		if err != nil {
 			return err
 		}
+		client.SetProxyAuthenticator(proxyAuth)
+		client.SetRequestSigner(signer)
+		client.SetRetryPolicy(retryPolicy)
+		if clientFlags.Common.Debug {
+			client.SetTracer(debugTracer{})
+		}
 		cli.client = client
 
 		if cli.in != nil {
@@ -191,19 +211,31 @@ func newHTTPClient(host string, tlsOptions *tlsconfig.Options) (*http.Client, er
 		return nil, nil
 	}
 
-	config, err := tlsconfig.Client(*tlsOptions)
+	proto, addr, _, err := client.ParseHost(host)
 	if err != nil {
 		return nil, err
 	}
-	tr := &http.Transport{
-		TLSClientConfig: config,
+	if proto == "ssh" {
+		return nil, errors.New("TLS options are not supported with ssh:// hosts")
 	}
-	proto, addr, _, err := client.ParseHost(host)
+
+	config, err := tlsconfig.Client(*tlsOptions)
 	if err != nil {
 		return nil, err
 	}
+	tr := &http.Transport{
+		TLSClientConfig: config,
+	}
 
-	sockets.ConfigureTransport(tr, proto, addr)
+	if proto != "srv" {
+		// srv hosts are dialed by the api client once it resolved them.
+		sockets.ConfigureTransport(tr, proto, addr)
+	}
+	if proto == "tcp" {
+		if err := transport.ConfigureProxy(tr, 0); err != nil {
+			return nil, err
+		}
+	}
 
 	return &http.Client{
 		Transport: tr,
@@ -0,0 +1,78 @@
+package client
+
+import (
+	"fmt"
+
+	"golang.org/x/net/context"
+
+	Cli "github.com/docker/docker/cli"
+	"github.com/docker/docker/pkg/jsonmessage"
+	flag "github.com/docker/docker/pkg/mflag"
+	"github.com/docker/docker/reference"
+	"github.com/docker/docker/registry"
+	"github.com/docker/engine-api/client"
+	"github.com/docker/engine-api/types"
+)
+
+// CmdImage is the parent subcommand for all image commands
+//
+// Usage: docker image <COMMAND> <OPTS>
+func (cli *DockerCli) CmdImage(args ...string) error {
+	description := Cli.DockerCommands["image"].Description + "\n\nCommands:\n"
+	commands := [][]string{
+		{"verify", "Verify the layers of an image stored on disk"},
+	}
+
+	for _, cmd := range commands {
+		description += fmt.Sprintf("  %-25.25s%s\n", cmd[0], cmd[1])
+	}
+
+	description += "\nRun 'docker image COMMAND --help' for more information on a command"
+	cmd := Cli.Subcmd("image", []string{"[COMMAND]"}, description, false)
+
+	cmd.Require(flag.Exact, 0)
+	err := cmd.ParseFlags(args, true)
+	cmd.Usage()
+	return err
+}
+
+// CmdImageVerify hashes again the layers of an image stored on disk, and
+// reports the layers whose content does not match their digest.
+//
+// Usage: docker image verify [OPTIONS] IMAGE
+func (cli *DockerCli) CmdImageVerify(args ...string) error {
+	cmd := Cli.Subcmd("image verify", []string{"IMAGE"}, "Verify the layers of an image stored on disk", true)
+	repull := cmd.Bool([]string{"-repull"}, false, "Pull the image again if its layers are corrupted")
+	cmd.Require(flag.Exact, 1)
+	cmd.ParseFlags(args, true)
+
+	options := types.ImageVerifyOptions{
+		ImageID: cmd.Arg(0),
+		Repull:  *repull,
+	}
+	requestPrivilege := client.RequestPrivilegeFunc(func() (string, error) {
+		return "", fmt.Errorf("unauthorized to pull %s again", cmd.Arg(0))
+	})
+	// The credentials of the registry of a named image are sent to pull it
+	// again.
+	if ref, err := reference.ParseNamed(cmd.Arg(0)); err == nil && *repull {
+		repoInfo, err := registry.ParseRepositoryInfo(ref)
+		if err != nil {
+			return err
+		}
+		encodedAuth, err := encodeAuthToBase64(cli.resolveAuthConfig(repoInfo.Index))
+		if err != nil {
+			return err
+		}
+		options.RegistryAuth = encodedAuth
+		requestPrivilege = cli.registryAuthenticationPrivilegedFunc(repoInfo.Index, "image verify")
+	}
+
+	responseBody, err := cli.client.ImageVerify(context.Background(), options, requestPrivilege)
+	if err != nil {
+		return err
+	}
+	defer responseBody.Close()
+
+	return jsonmessage.DisplayJSONMessagesStream(responseBody, cli.out, cli.outFd, cli.isTerminalOut, nil)
+}
@@ -2,7 +2,9 @@ package client
 
 import (
 	"fmt"
+	"sort"
 	"strings"
+	"time"
 
 	Cli "github.com/docker/docker/cli"
 	"github.com/docker/docker/pkg/ioutils"
@@ -97,6 +99,49 @@ func (cli *DockerCli) CmdInfo(args ...string) error {
 		fmt.Fprintf(cli.out, "Registry: %v\n", info.IndexServerAddress)
 	}
 
+	if len(info.RegistryMirrors) > 0 {
+		fmt.Fprintln(cli.out, "Registry Mirrors:")
+		for _, mirror := range info.RegistryMirrors {
+			status := "healthy"
+			if !mirror.Healthy {
+				status = "unhealthy"
+			}
+			if !mirror.LastCheck.IsZero() {
+				status += fmt.Sprintf(", latency %dms, error rate %.0f%%", mirror.Latency/time.Millisecond, mirror.ErrorRate*100)
+			}
+			fmt.Fprintf(cli.out, " %s (%s)\n", mirror.URL, status)
+			if !mirror.Healthy && mirror.LastError != "" {
+				fmt.Fprintf(cli.out, "  Last Error: %s\n", mirror.LastError)
+			}
+		}
+	}
+
+	if info.RegistryConfig != nil && len(info.RegistryConfig.TLSConfigs) > 0 {
+		fmt.Fprintln(cli.out, "Registry TLS Settings:")
+		hosts := make([]string, 0, len(info.RegistryConfig.TLSConfigs))
+		for host := range info.RegistryConfig.TLSConfigs {
+			hosts = append(hosts, host)
+		}
+		sort.Strings(hosts)
+		for _, host := range hosts {
+			settings := info.RegistryConfig.TLSConfigs[host]
+			var fields []string
+			if settings.MinVersion != "" {
+				fields = append(fields, "min-version="+settings.MinVersion)
+			}
+			if len(settings.CipherSuites) > 0 {
+				fields = append(fields, "cipher-suites="+strings.Join(settings.CipherSuites, ":"))
+			}
+			if settings.ServerName != "" {
+				fields = append(fields, "server-name="+settings.ServerName)
+			}
+			if settings.InsecureSkipVerify {
+				fields = append(fields, "insecure-skip-verify=true")
+			}
+			fmt.Fprintf(cli.out, " %s: %s\n", host, strings.Join(fields, ","))
+		}
+	}
+
 	// Only output these warnings if the server does not support these features
 	if info.OSType != "windows" {
 		if !info.MemoryLimit {
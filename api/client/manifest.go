@@ -0,0 +1,254 @@
+package client
+
+import (
+	"fmt"
+	"path/filepath"
+
+	Cli "github.com/docker/docker/cli"
+	"github.com/docker/docker/cliconfig"
+	"github.com/docker/docker/dockerversion"
+	flag "github.com/docker/docker/pkg/mflag"
+	"github.com/docker/docker/reference"
+	"github.com/docker/docker/registry"
+	"github.com/docker/docker/registry/bundle"
+	"github.com/docker/docker/registry/manifestlist"
+)
+
+// CmdManifest is the parent subcommand for all manifest commands
+//
+// Usage: docker manifest <COMMAND> <OPTS>
+func (cli *DockerCli) CmdManifest(args ...string) error {
+	description := Cli.DockerCommands["manifest"].Description + "\n\nCommands:\n"
+	commands := [][]string{
+		{"create", "Create a manifest list from pushed images"},
+		{"annotate", "Set the platform of an image of a manifest list"},
+		{"push", "Push a manifest list to a registry"},
+	}
+
+	for _, cmd := range commands {
+		description += fmt.Sprintf("  %-25.25s%s\n", cmd[0], cmd[1])
+	}
+
+	description += "\nRun 'docker manifest COMMAND --help' for more information on a command"
+	cmd := Cli.Subcmd("manifest", []string{"[COMMAND]"}, description, false)
+
+	cmd.Require(flag.Exact, 0)
+	err := cmd.ParseFlags(args, true)
+	cmd.Usage()
+	return err
+}
+
+// CmdManifestCreate creates a local manifest list from images pushed to the
+// registry of the list.
+//
+// Usage: docker manifest create [OPTIONS] LIST IMAGE [IMAGE...]
+func (cli *DockerCli) CmdManifestCreate(args ...string) error {
+	cmd := Cli.Subcmd("manifest create", []string{"LIST IMAGE [IMAGE...]"}, "Create a manifest list from pushed images", true)
+	amend := cmd.Bool([]string{"a", "-amend"}, false, "Add the images to an existing manifest list")
+	cmd.Require(flag.Min, 2)
+	cmd.ParseFlags(args, true)
+
+	list, err := parseManifestList(cmd.Arg(0))
+	if err != nil {
+		return err
+	}
+	store := manifestListStore()
+
+	var images []manifestlist.Image
+	if store.Exists(list.String()) {
+		if !*amend {
+			return fmt.Errorf("manifest list %s already exists, use --amend to add images to it", list.String())
+		}
+		if images, err = store.Get(list.String()); err != nil {
+			return err
+		}
+	}
+
+	service := registry.NewService(registry.ServiceOptions{})
+	for _, arg := range cmd.Args()[1:] {
+		ref, err := reference.ParseNamed(arg)
+		if err != nil {
+			return err
+		}
+		if ref.Hostname() != list.Hostname() {
+			return fmt.Errorf("%s is not on the registry of %s", arg, list.Name())
+		}
+		tagOrDigest := reference.DefaultTag
+		switch x := ref.(type) {
+		case reference.Canonical:
+			tagOrDigest = x.Digest().String()
+		case reference.NamedTagged:
+			tagOrDigest = x.Tag()
+		}
+		name, err := reference.WithName(ref.Name())
+		if err != nil {
+			return err
+		}
+		repo, err := cli.manifestRepository(service, name, "pull")
+		if err != nil {
+			return err
+		}
+		img, err := manifestlist.Inspect(repo, name, tagOrDigest)
+		if err != nil {
+			return err
+		}
+		images = addManifestListImage(images, img)
+	}
+
+	if err := store.Save(list.String(), images); err != nil {
+		return err
+	}
+	fmt.Fprintf(cli.out, "Created manifest list %s\n", list.String())
+	return nil
+}
+
+// addManifestListImage adds an image to the images of a list, replacing the
+// image of the same manifest.
+func addManifestListImage(images []manifestlist.Image, img manifestlist.Image) []manifestlist.Image {
+	for i := range images {
+		if images[i].Descriptor.Digest == img.Descriptor.Digest {
+			images[i] = img
+			return images
+		}
+	}
+	return append(images, img)
+}
+
+// CmdManifestAnnotate sets the platform of an image of a local manifest list.
+//
+// Usage: docker manifest annotate [OPTIONS] LIST IMAGE
+func (cli *DockerCli) CmdManifestAnnotate(args ...string) error {
+	cmd := Cli.Subcmd("manifest annotate", []string{"LIST IMAGE"}, "Set the platform of an image of a manifest list", true)
+	platformOS := cmd.String([]string{"-os"}, "", "Set the operating system of the image")
+	arch := cmd.String([]string{"-arch"}, "", "Set the architecture of the image")
+	variant := cmd.String([]string{"-variant"}, "", "Set the architecture variant of the image")
+	cmd.Require(flag.Exact, 2)
+	cmd.ParseFlags(args, true)
+
+	list, err := parseManifestList(cmd.Arg(0))
+	if err != nil {
+		return err
+	}
+	store := manifestListStore()
+	images, err := store.Get(list.String())
+	if err != nil {
+		return err
+	}
+
+	i, err := findManifestListImage(images, cmd.Arg(1))
+	if err != nil {
+		return err
+	}
+	platform := &images[i].Descriptor.Platform
+	if *platformOS != "" {
+		platform.OS = *platformOS
+	}
+	if *arch != "" {
+		platform.Architecture = *arch
+	}
+	if *variant != "" {
+		platform.Variant = *variant
+	}
+	return store.Save(list.String(), images)
+}
+
+// findManifestListImage returns the index of an image of a list, referenced
+// by digest, or by name when the list holds a single image of that name.
+func findManifestListImage(images []manifestlist.Image, image string) (int, error) {
+	ref, err := reference.ParseNamed(image)
+	if err != nil {
+		return 0, err
+	}
+	found := -1
+	for i, img := range images {
+		imgRef, err := reference.ParseNamed(img.Ref)
+		if err != nil {
+			return 0, err
+		}
+		if imgRef.Name() != ref.Name() {
+			continue
+		}
+		if canonical, ok := ref.(reference.Canonical); ok {
+			if canonical.Digest() == img.Descriptor.Digest {
+				return i, nil
+			}
+			continue
+		}
+		if found >= 0 {
+			return 0, fmt.Errorf("the manifest list holds several images of %s, the image must be referenced by digest", ref.Name())
+		}
+		found = i
+	}
+	if found < 0 {
+		return 0, fmt.Errorf("the manifest list holds no image %s", image)
+	}
+	return found, nil
+}
+
+// CmdManifestPush pushes a local manifest list to its registry.
+//
+// Usage: docker manifest push [OPTIONS] LIST
+func (cli *DockerCli) CmdManifestPush(args ...string) error {
+	cmd := Cli.Subcmd("manifest push", []string{"LIST"}, "Push a manifest list to a registry", true)
+	purge := cmd.Bool([]string{"p", "-purge"}, false, "Remove the local manifest list once pushed")
+	cmd.Require(flag.Exact, 1)
+	cmd.ParseFlags(args, true)
+
+	list, err := parseManifestList(cmd.Arg(0))
+	if err != nil {
+		return err
+	}
+	store := manifestListStore()
+	images, err := store.Get(list.String())
+	if err != nil {
+		return err
+	}
+
+	service := registry.NewService(registry.ServiceOptions{})
+	dst, err := cli.manifestRepository(service, list, "push", "pull")
+	if err != nil {
+		return err
+	}
+	open := func(ref reference.Named) (manifestlist.Source, error) {
+		return cli.manifestRepository(service, ref, "pull")
+	}
+	if _, err := manifestlist.Push(dst, list, list.Tag(), images, open, cli.out); err != nil {
+		return err
+	}
+	if *purge {
+		return store.Remove(list.String())
+	}
+	return nil
+}
+
+// parseManifestList parses the reference of a manifest list, which is
+// tagged latest unless it has another tag.
+func parseManifestList(s string) (reference.NamedTagged, error) {
+	ref, err := reference.ParseNamed(s)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := ref.(reference.Canonical); ok {
+		return nil, fmt.Errorf("manifest list %s must be referenced by tag", s)
+	}
+	tagged, ok := reference.WithDefaultTag(ref).(reference.NamedTagged)
+	if !ok {
+		return nil, fmt.Errorf("invalid manifest list %s", s)
+	}
+	return tagged, nil
+}
+
+func manifestListStore() *manifestlist.Store {
+	return manifestlist.NewStore(filepath.Join(cliconfig.ConfigDir(), "manifests"))
+}
+
+// manifestRepository connects to the repository of an image with the
+// credentials of the user.
+func (cli *DockerCli) manifestRepository(service *registry.Service, name reference.Named, actions ...string) (*bundle.Repository, error) {
+	repoInfo, err := registry.ParseRepositoryInfo(name)
+	if err != nil {
+		return nil, err
+	}
+	authConfig := cli.resolveAuthConfig(repoInfo.Index)
+	return bundle.NewRepository(service, repoInfo, authConfig, dockerversion.DockerUserAgent(), actions...)
+}
@@ -0,0 +1,225 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"golang.org/x/net/context"
+
+	Cli "github.com/docker/docker/cli"
+	"github.com/docker/docker/dockerversion"
+	"github.com/docker/docker/pkg/jsonmessage"
+	flag "github.com/docker/docker/pkg/mflag"
+	"github.com/docker/docker/reference"
+	"github.com/docker/docker/registry"
+	"github.com/docker/docker/registry/bundle"
+)
+
+// CmdRegistryBundle is the parent subcommand for all registry-bundle commands
+//
+// Usage: docker registry-bundle <COMMAND> <OPTS>
+func (cli *DockerCli) CmdRegistryBundle(args ...string) error {
+	description := Cli.DockerCommands["registry-bundle"].Description + "\n\nCommands:\n"
+	commands := [][]string{
+		{"export", "Export images from a registry to a bundle file"},
+		{"import", "Load the images of a bundle file"},
+		{"push", "Push the images of a bundle file to a registry"},
+	}
+
+	for _, cmd := range commands {
+		description += fmt.Sprintf("  %-25.25s%s\n", cmd[0], cmd[1])
+	}
+
+	description += "\nRun 'docker registry-bundle COMMAND --help' for more information on a command"
+	cmd := Cli.Subcmd("registry-bundle", []string{"[COMMAND]"}, description, false)
+
+	cmd.Require(flag.Exact, 0)
+	err := cmd.ParseFlags(args, true)
+	cmd.Usage()
+	return err
+}
+
+// CmdRegistryBundleExport exports images from a registry to a bundle file,
+// with their manifests and layers unchanged.
+//
+// Usage: docker registry-bundle export -o FILE NAME[:TAG|@DIGEST] [NAME[:TAG|@DIGEST]...]
+func (cli *DockerCli) CmdRegistryBundleExport(args ...string) error {
+	cmd := Cli.Subcmd("registry-bundle export", []string{"NAME[:TAG|@DIGEST] [NAME[:TAG|@DIGEST]...]"}, "Export images from a registry to a bundle file", true)
+	outfile := cmd.String([]string{"o", "-output"}, "", "Write the bundle to a file")
+	cmd.Require(flag.Min, 1)
+	cmd.ParseFlags(args, true)
+
+	if *outfile == "" {
+		return errors.New("the bundle file must be set with the -o flag")
+	}
+
+	file, err := os.Create(*outfile)
+	if err != nil {
+		return err
+	}
+	bw, err := bundle.NewWriter(file)
+	if err == nil {
+		err = cli.exportBundleImages(bw, cmd.Args())
+	}
+	if err == nil {
+		err = bw.Close()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(*outfile)
+	}
+	return err
+}
+
+func (cli *DockerCli) exportBundleImages(bw *bundle.Writer, images []string) error {
+	service := registry.NewService(registry.ServiceOptions{})
+	for _, image := range images {
+		ref, err := reference.ParseNamed(image)
+		if err != nil {
+			return err
+		}
+		if reference.IsNameOnly(ref) {
+			ref = reference.WithDefaultTag(ref)
+		}
+		var tagOrDigest string
+		switch x := ref.(type) {
+		case reference.Canonical:
+			tagOrDigest = x.Digest().String()
+		case reference.NamedTagged:
+			tagOrDigest = x.Tag()
+		}
+
+		repoInfo, err := registry.ParseRepositoryInfo(ref)
+		if err != nil {
+			return err
+		}
+		authConfig := cli.resolveAuthConfig(repoInfo.Index)
+		repo, err := bundle.NewRepository(service, repoInfo, authConfig, dockerversion.DockerUserAgent(), "pull")
+		if err != nil {
+			return err
+		}
+		if err := bundle.Export(bw, repo, ref.String(), tagOrDigest, cli.out); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CmdRegistryBundleImport loads the images of a bundle file, picking the
+// images of the platform of the daemon from the manifest lists.
+//
+// Usage: docker registry-bundle import [OPTIONS] FILE
+func (cli *DockerCli) CmdRegistryBundleImport(args ...string) error {
+	cmd := Cli.Subcmd("registry-bundle import", []string{"FILE"}, "Load the images of a bundle file", true)
+	quiet := cmd.Bool([]string{"q", "-quiet"}, false, "Suppress the load output")
+	cmd.Require(flag.Exact, 1)
+	cmd.ParseFlags(args, true)
+
+	br, file, err := openBundle(cmd.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	serverVersion, err := cli.client.ServerVersion()
+	if err != nil {
+		return err
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(bundle.WriteDockerArchive(pw, br, serverVersion.Os, serverVersion.Arch))
+	}()
+	defer pr.Close()
+
+	if !cli.isTerminalOut {
+		*quiet = true
+	}
+	response, err := cli.client.ImageLoad(context.Background(), pr, *quiet)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.JSON {
+		return jsonmessage.DisplayJSONMessagesStream(response.Body, cli.out, cli.outFd, cli.isTerminalOut, nil)
+	}
+
+	_, err = io.Copy(cli.out, response.Body)
+	return err
+}
+
+// CmdRegistryBundlePush pushes the images of a bundle file to the registries
+// they were exported from, or to another registry. The images keep their
+// digests.
+//
+// Usage: docker registry-bundle push [OPTIONS] FILE
+func (cli *DockerCli) CmdRegistryBundlePush(args ...string) error {
+	cmd := Cli.Subcmd("registry-bundle push", []string{"FILE"}, "Push the images of a bundle file to a registry", true)
+	target := cmd.String([]string{"-registry"}, "", "Push to this registry instead of the registries the images were exported from")
+	cmd.Require(flag.Exact, 1)
+	cmd.ParseFlags(args, true)
+
+	br, file, err := openBundle(cmd.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	service := registry.NewService(registry.ServiceOptions{})
+	for _, desc := range br.Index().Manifests {
+		ref, err := reference.ParseNamed(desc.Annotations[bundle.RefNameAnnotation])
+		if err != nil {
+			return err
+		}
+		var tag string
+		if tagged, ok := ref.(reference.NamedTagged); ok {
+			tag = tagged.Tag()
+		}
+		if *target != "" {
+			if ref, err = reference.WithName(*target + "/" + ref.RemoteName()); err != nil {
+				return err
+			}
+		}
+
+		repoInfo, err := registry.ParseRepositoryInfo(ref)
+		if err != nil {
+			return err
+		}
+		authConfig := cli.resolveAuthConfig(repoInfo.Index)
+		repo, err := bundle.NewRepository(service, repoInfo, authConfig, dockerversion.DockerUserAgent(), "push", "pull")
+		if err != nil {
+			return err
+		}
+		desc.Annotations = nil
+		fmt.Fprintf(cli.out, "Pushing %s\n", ref.Name())
+		if err := bundle.Push(br, desc, repo, tag, cli.out); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// openBundle opens a bundle file. The file must be closed once the bundle is
+// read.
+func openBundle(filename string) (*bundle.Reader, *os.File, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+	fi, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+	br, err := bundle.NewReader(file, fi.Size())
+	if err != nil {
+		file.Close()
+		return nil, nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	return br, file, nil
+}
@@ -0,0 +1,38 @@
+package client
+
+import (
+	"bytes"
+	"crypto"
+	"os"
+
+	"github.com/docker/engine-api/client"
+	"github.com/docker/libtrust"
+)
+
+// trustKeySigner signs API requests with a libtrust private key, whose
+// public key is listed in the daemon's --api-authorized-keys.
+type trustKeySigner struct {
+	key libtrust.PrivateKey
+}
+
+func (s trustKeySigner) KeyID() string {
+	return s.key.KeyID()
+}
+
+func (s trustKeySigner) Sign(payload []byte) ([]byte, string, error) {
+	return s.key.Sign(bytes.NewReader(payload), crypto.SHA256)
+}
+
+// requestSignerFromEnv returns the signer configured by the environment:
+// the private key file named by DOCKER_API_SIGNING_KEY, or the shared
+// secret in DOCKER_API_HMAC_KEY. It returns nil if neither is set.
+func requestSignerFromEnv() (client.RequestSigner, error) {
+	if keyFile := os.Getenv("DOCKER_API_SIGNING_KEY"); keyFile != "" {
+		key, err := libtrust.LoadKeyFile(keyFile)
+		if err != nil {
+			return nil, err
+		}
+		return trustKeySigner{key: key}, nil
+	}
+	return client.RequestSignerFromEnv()
+}
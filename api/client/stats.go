@@ -40,24 +40,17 @@ func (cli *DockerCli) CmdStats(args ...string) error {
 		options := types.EventsOptions{
 			Filters: f,
 		}
-		resBody, err := cli.client.Events(context.Background(), options)
+		eventStream, errs := cli.client.EventStream(context.Background(), options)
 		// Whether we successfully subscribed to events or not, we can now
 		// unblock the main goroutine.
 		close(started)
-		if err != nil {
-			closeChan <- err
-			return
-		}
-		defer resBody.Close()
 
-		decodeEvents(resBody, func(event events.Message, err error) error {
-			if err != nil {
-				closeChan <- err
-				return nil
-			}
+		for event := range eventStream {
 			c <- event
-			return nil
-		})
+		}
+		if err := <-errs; err != nil {
+			closeChan <- err
+		}
 	}
 
 	// waitFirst is a WaitGroup to wait first stat data's reach for each container
@@ -1,7 +1,6 @@
 package client
 
 import (
-	"encoding/json"
 	"fmt"
 	"io"
 	"strings"
@@ -77,24 +76,12 @@ func (s *containerStats) Collect(cli client.APIClient, streamStats bool, waitFir
 		}
 	}()
 
-	responseBody, err := cli.ContainerStats(context.Background(), s.Name, streamStats)
-	if err != nil {
-		s.mu.Lock()
-		s.err = err
-		s.mu.Unlock()
-		return
-	}
-	defer responseBody.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 
-	dec := json.NewDecoder(responseBody)
+	statsStream, errs := cli.ContainerStatsStream(ctx, s.Name, streamStats)
 	go func() {
-		for {
-			var v *types.StatsJSON
-			if err := dec.Decode(&v); err != nil {
-				u <- err
-				return
-			}
-
+		for v := range statsStream {
 			var memPercent = 0.0
 			var cpuPercent = 0.0
 
@@ -106,7 +93,7 @@ func (s *containerStats) Collect(cli client.APIClient, streamStats bool, waitFir
 
 			previousCPU = v.PreCPUStats.CPUUsage.TotalUsage
 			previousSystem = v.PreCPUStats.SystemUsage
-			cpuPercent = calculateCPUPercent(previousCPU, previousSystem, v)
+			cpuPercent = calculateCPUPercent(previousCPU, previousSystem, &v)
 			blkRead, blkWrite := calculateBlockIO(v.BlkioStats)
 			s.mu.Lock()
 			s.CPUPercentage = cpuPercent
@@ -123,6 +110,11 @@ func (s *containerStats) Collect(cli client.APIClient, streamStats bool, waitFir
 				return
 			}
 		}
+		err := <-errs
+		if err == nil {
+			err = io.EOF
+		}
+		u <- err
 	}()
 	for {
 		select {
@@ -0,0 +1,28 @@
+package client
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"golang.org/x/net/context"
+)
+
+// debugTracer logs every API request sent to the daemon at debug level.
+type debugTracer struct{}
+
+func (debugTracer) RequestStart(ctx context.Context, req *http.Request) context.Context {
+	return ctx
+}
+
+func (debugTracer) RequestRetry(ctx context.Context, method, path string, attempt int, err error) {
+	logrus.Debugf("Retrying %s %s (retry %d): %v", method, path, attempt, err)
+}
+
+func (debugTracer) RequestResponse(ctx context.Context, req *http.Request, resp *http.Response, duration time.Duration) {
+	logrus.Debugf("%s %s: %s (%s)", req.Method, req.URL.Path, resp.Status, duration)
+}
+
+func (debugTracer) RequestError(ctx context.Context, req *http.Request, err error, duration time.Duration) {
+	logrus.Debugf("%s %s failed (%s): %v", req.Method, req.URL.Path, duration, err)
+}
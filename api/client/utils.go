@@ -90,7 +90,7 @@ func getExitCode(cli *DockerCli, containerID string) (bool, int, error) {
 	c, err := cli.client.ContainerInspect(containerID)
 	if err != nil {
 		// If we can't connect, then the daemon probably died.
-		if err != client.ErrConnectionFailed {
+		if !client.IsErrConnectionFailed(err) {
 			return false, -1, err
 		}
 		return false, -1, nil
@@ -105,7 +105,7 @@ func getExecExitCode(cli *DockerCli, execID string) (bool, int, error) {
 	resp, err := cli.client.ContainerExecInspect(execID)
 	if err != nil {
 		// If we can't connect, then the daemon probably died.
-		if err != client.ErrConnectionFailed {
+		if !client.IsErrConnectionFailed(err) {
 			return false, -1, err
 		}
 		return false, -1, nil
@@ -0,0 +1,17 @@
+// +build go1.24
+
+package server
+
+import "net/http"
+
+// configureHTTP2 makes the server accept HTTP/2 connections, negotiated
+// through ALPN on TLS listeners and with prior knowledge (h2c) on the others,
+// in addition to HTTP/1.1.
+func configureHTTP2(srv *http.Server) error {
+	var protocols http.Protocols
+	protocols.SetHTTP1(true)
+	protocols.SetHTTP2(true)
+	protocols.SetUnencryptedHTTP2(true)
+	srv.Protocols = &protocols
+	return nil
+}
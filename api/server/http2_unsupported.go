@@ -0,0 +1,14 @@
+// +build !go1.24
+
+package server
+
+import (
+	"errors"
+	"net/http"
+)
+
+// configureHTTP2 is not supported when the daemon is built with a Go version
+// whose http package can't serve unencrypted HTTP/2.
+func configureHTTP2(srv *http.Server) error {
+	return errors.New("HTTP/2 is not supported by this build of the daemon")
+}
@@ -1,6 +1,7 @@
 package httputils
 
 import (
+	"crypto/sha256"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -90,6 +91,42 @@ func WriteJSON(w http.ResponseWriter, code int, v interface{}) error {
 	return json.NewEncoder(w).Encode(v)
 }
 
+// WriteJSONWithETag writes the value v to the http response stream as json,
+// like WriteJSON, along with an ETag computed from its content. Requests
+// with an If-None-Match header matching the ETag get a 304 Not Modified
+// response without body.
+func WriteJSONWithETag(w http.ResponseWriter, r *http.Request, v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+	sum := sha256.Sum256(b)
+	etag := fmt.Sprintf(`"%x"`, sum[:16])
+
+	w.Header().Set("ETag", etag)
+	if matchesETag(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(b)
+	return err
+}
+
+// matchesETag returns true if the value of an If-None-Match header
+// matches the etag.
+func matchesETag(ifNoneMatch, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == etag || candidate == "*" {
+			return true
+		}
+	}
+	return false
+}
+
 // VersionFromContext returns an API version from the context using APIVersionKey.
 // It panics if the context value does not have version.Version type.
 func VersionFromContext(ctx context.Context) (ver version.Version) {
@@ -0,0 +1,44 @@
+package httputils
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWriteJSONWithETag(t *testing.T) {
+	v := map[string]string{"Version": "1.11.0"}
+
+	r, _ := http.NewRequest("GET", "/version", nil)
+	w := httptest.NewRecorder()
+	if err := WriteJSONWithETag(w, r, v); err != nil {
+		t.Fatal(err)
+	}
+	etag := w.Header().Get("ETag")
+	if w.Code != http.StatusOK || etag == "" {
+		t.Fatalf("expected a 200 response with an ETag, got %d %q", w.Code, etag)
+	}
+	if w.Body.String() != "{\"Version\":\"1.11.0\"}\n" {
+		t.Fatalf("unexpected body %q", w.Body.String())
+	}
+
+	for _, ifNoneMatch := range []string{etag, "W/" + etag, `"other", ` + etag, "*"} {
+		r.Header.Set("If-None-Match", ifNoneMatch)
+		w = httptest.NewRecorder()
+		if err := WriteJSONWithETag(w, r, v); err != nil {
+			t.Fatal(err)
+		}
+		if w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+			t.Fatalf("If-None-Match %s: expected an empty 304 response, got %d %q", ifNoneMatch, w.Code, w.Body.String())
+		}
+	}
+
+	r.Header.Set("If-None-Match", `"other"`)
+	w = httptest.NewRecorder()
+	if err := WriteJSONWithETag(w, r, v); err != nil {
+		t.Fatal(err)
+	}
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected a 200 response for a different ETag, got %d", w.Code)
+	}
+}
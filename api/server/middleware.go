@@ -37,5 +37,15 @@ func (s *Server) handleWithGlobalMiddlewares(handler httputils.APIFunc) httputil
 		next = handleAuthorization(next)
 	}
 
+	// Signatures cover the bodies as sent, so they're verified before the
+	// bodies are decompressed.
+	handleCompression := middleware.NewCompressionMiddleware()
+	next = handleCompression(next)
+
+	if s.cfg.SignatureKeys != nil {
+		handleSignature := middleware.NewSignatureMiddleware(s.cfg.SignatureKeys)
+		next = handleSignature(next)
+	}
+
 	return next
 }
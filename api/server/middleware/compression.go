@@ -0,0 +1,223 @@
+package middleware
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/docker/docker/api/server/httputils"
+	"github.com/docker/docker/errors"
+	"golang.org/x/net/context"
+)
+
+// Encoding is a content coding the daemon can compress responses with,
+// and decompress requests from.
+type Encoding struct {
+	// Name is the name of the coding in the HTTP headers, like gzip.
+	Name string
+	// NewWriter returns a writer compressing to w.
+	NewWriter func(w io.Writer) (io.WriteCloser, error)
+	// NewReader returns a reader decompressing r.
+	NewReader func(r io.Reader) (io.ReadCloser, error)
+}
+
+// Encodings lists the codings supported by the daemon, the preferred ones
+// first. zstd would come first, but there's no encoder for it in the
+// standard library.
+var Encodings = []Encoding{
+	{
+		Name: "gzip",
+		NewWriter: func(w io.Writer) (io.WriteCloser, error) {
+			return gzip.NewWriter(w), nil
+		},
+		NewReader: func(r io.Reader) (io.ReadCloser, error) {
+			return gzip.NewReader(r)
+		},
+	},
+}
+
+func lookupEncoding(name string) *Encoding {
+	for i := range Encodings {
+		if strings.EqualFold(Encodings[i].Name, name) {
+			return &Encodings[i]
+		}
+	}
+	return nil
+}
+
+// negotiateEncoding returns the preferred coding accepted by a client,
+// or nil if it accepts none of them.
+func negotiateEncoding(acceptEncoding string) *Encoding {
+	accepted := make(map[string]bool)
+	wildcard := false
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		params := strings.Split(part, ";")
+		name := strings.ToLower(strings.TrimSpace(params[0]))
+		ok := true
+		for _, param := range params[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if q, err := strconv.ParseFloat(param[2:], 64); err != nil || q <= 0 {
+					ok = false
+				}
+			}
+		}
+		if name == "*" {
+			wildcard = ok
+			continue
+		}
+		accepted[name] = ok
+	}
+	for i := range Encodings {
+		ok, listed := accepted[Encodings[i].Name]
+		if ok || (!listed && wildcard) {
+			return &Encodings[i]
+		}
+	}
+	return nil
+}
+
+// NewCompressionMiddleware decompresses the bodies of requests sent with a
+// Content-Encoding, and compresses the JSON responses of clients sending an
+// Accept-Encoding with a supported coding. Raw streams, like the output of
+// attach or exports, are not compressed.
+func NewCompressionMiddleware() Middleware {
+	return func(handler httputils.APIFunc) httputils.APIFunc {
+		return func(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+			if name := r.Header.Get("Content-Encoding"); name != "" && name != "identity" {
+				encoding := lookupEncoding(name)
+				if encoding == nil {
+					return errors.NewErrorWithStatusCode(fmt.Errorf("unsupported content encoding: %s", name), http.StatusUnsupportedMediaType)
+				}
+				body, err := encoding.NewReader(r.Body)
+				if err != nil {
+					return errors.NewBadRequestError(err)
+				}
+				defer body.Close()
+				r.Body = body
+				r.Header.Del("Content-Encoding")
+				r.Header.Del("Content-Length")
+				r.ContentLength = -1
+			}
+
+			if r.Method == "HEAD" {
+				return handler(ctx, w, r, vars)
+			}
+			encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"))
+			if encoding == nil {
+				return handler(ctx, w, r, vars)
+			}
+			cw := &compressResponseWriter{ResponseWriter: w, encoding: encoding}
+			defer cw.close()
+			return handler(ctx, cw, r, vars)
+		}
+	}
+}
+
+// errResponseClosed is returned by writes happening after the handler returned.
+var errResponseClosed = fmt.Errorf("response already completed")
+
+// compressResponseWriter compresses JSON responses. The decision is taken
+// when the status is written, from the Content-Type set by the handler.
+type compressResponseWriter struct {
+	http.ResponseWriter
+	encoding *Encoding
+
+	mu          sync.Mutex
+	wroteHeader bool
+	enc         io.WriteCloser
+	closed      bool
+}
+
+func (cw *compressResponseWriter) WriteHeader(code int) {
+	cw.mu.Lock()
+	defer cw.mu.Unlock()
+	cw.writeHeader(code)
+}
+
+func (cw *compressResponseWriter) writeHeader(code int) {
+	if cw.wroteHeader {
+		return
+	}
+	cw.wroteHeader = true
+
+	h := cw.Header()
+	mediaType, _, _ := mime.ParseMediaType(h.Get("Content-Type"))
+	if mediaType == "application/json" && h.Get("Content-Encoding") == "" &&
+		code >= http.StatusOK && code != http.StatusNoContent && code != http.StatusNotModified {
+		enc, err := cw.encoding.NewWriter(cw.ResponseWriter)
+		if err == nil {
+			cw.enc = enc
+			h.Set("Content-Encoding", cw.encoding.Name)
+			h.Del("Content-Length")
+		}
+	}
+	h.Add("Vary", "Accept-Encoding")
+	cw.ResponseWriter.WriteHeader(code)
+}
+
+func (cw *compressResponseWriter) Write(b []byte) (int, error) {
+	cw.mu.Lock()
+	defer cw.mu.Unlock()
+	if cw.closed {
+		return 0, errResponseClosed
+	}
+	cw.writeHeader(http.StatusOK)
+	if cw.enc == nil {
+		return cw.ResponseWriter.Write(b)
+	}
+	return cw.enc.Write(b)
+}
+
+// Flush sends the data compressed so far to the client, so that streams
+// of JSON messages, like events, are not delayed.
+func (cw *compressResponseWriter) Flush() {
+	cw.mu.Lock()
+	defer cw.mu.Unlock()
+	if cw.closed {
+		return
+	}
+	if flusher, ok := cw.enc.(interface {
+		Flush() error
+	}); ok {
+		flusher.Flush()
+	}
+	if flusher, ok := cw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// CloseNotify uses the close notification of the wrapped http.ResponseWriter.
+func (cw *compressResponseWriter) CloseNotify() <-chan bool {
+	if notifier, ok := cw.ResponseWriter.(http.CloseNotifier); ok {
+		return notifier.CloseNotify()
+	}
+	return make(chan bool)
+}
+
+// Hijack hijacks the connection of the wrapped http.ResponseWriter.
+// Hijacked connections are never compressed.
+func (cw *compressResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := cw.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("Internal response writer doesn't support the Hijacker interface")
+	}
+	return hijacker.Hijack()
+}
+
+// close terminates the compressed stream, if any, once the handler returned.
+func (cw *compressResponseWriter) close() {
+	cw.mu.Lock()
+	defer cw.mu.Unlock()
+	cw.closed = true
+	if cw.enc != nil {
+		cw.enc.Close()
+	}
+}
@@ -0,0 +1,120 @@
+package middleware
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/api/server/httputils"
+	"golang.org/x/net/context"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	cases := map[string]string{
+		"":                 "",
+		"gzip":             "gzip",
+		"deflate, gzip":    "gzip",
+		"GZIP;q=0.5":       "gzip",
+		"gzip;q=0":         "",
+		"*":                "gzip",
+		"*, gzip;q=0":      "",
+		"br, identity":     "",
+		"zstd, gzip;q=0.8": "gzip",
+	}
+	for header, expected := range cases {
+		name := ""
+		if encoding := negotiateEncoding(header); encoding != nil {
+			name = encoding.Name
+		}
+		if name != expected {
+			t.Fatalf("Accept-Encoding %q: expected %q, got %q", header, expected, name)
+		}
+	}
+}
+
+func TestCompressionMiddlewareResponses(t *testing.T) {
+	h := NewCompressionMiddleware()(func(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+		if r.URL.Path == "/export" {
+			w.Header().Set("Content-Type", "application/x-tar")
+			_, err := w.Write([]byte("tar"))
+			return err
+		}
+		return httputils.WriteJSON(w, http.StatusOK, map[string]string{"Id": "abc"})
+	})
+
+	req, _ := http.NewRequest("GET", "/containers/json", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	if err := h(context.Background(), rec, req, map[string]string{}); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("expected a gzip response, got headers %v", rec.Header())
+	}
+	gz, err := gzip.NewReader(rec.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := ioutil.ReadAll(gz)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.TrimSpace(string(body)) != `{"Id":"abc"}` {
+		t.Fatalf("unexpected body %q", body)
+	}
+
+	req, _ = http.NewRequest("GET", "/containers/json", nil)
+	rec = httptest.NewRecorder()
+	if err := h(context.Background(), rec, req, map[string]string{}); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Header().Get("Content-Encoding") != "" {
+		t.Fatal("expected an uncompressed response without Accept-Encoding")
+	}
+
+	req, _ = http.NewRequest("GET", "/export", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec = httptest.NewRecorder()
+	if err := h(context.Background(), rec, req, map[string]string{}); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Header().Get("Content-Encoding") != "" || rec.Body.String() != "tar" {
+		t.Fatalf("expected raw streams to be sent as is, got %q", rec.Body.String())
+	}
+}
+
+func TestCompressionMiddlewareRequests(t *testing.T) {
+	var received string
+	h := NewCompressionMiddleware()(func(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+		b, err := ioutil.ReadAll(r.Body)
+		received = string(b)
+		return err
+	})
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	gz.Write([]byte(`{"Image":"busybox"}`))
+	gz.Close()
+	req, _ := http.NewRequest("POST", "/containers/create", &buf)
+	req.Header.Set("Content-Encoding", "gzip")
+	if err := h(context.Background(), httptest.NewRecorder(), req, map[string]string{}); err != nil {
+		t.Fatal(err)
+	}
+	if received != `{"Image":"busybox"}` {
+		t.Fatalf("expected the handler to read the decompressed body, got %q", received)
+	}
+
+	received = ""
+	req, _ = http.NewRequest("POST", "/containers/create", strings.NewReader("data"))
+	req.Header.Set("Content-Encoding", "br")
+	if err := h(context.Background(), httptest.NewRecorder(), req, map[string]string{}); err == nil {
+		t.Fatal("expected unsupported encodings to be rejected")
+	}
+	if received != "" {
+		t.Fatal("the handler must not be called")
+	}
+}
@@ -0,0 +1,175 @@
+package middleware
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/docker/docker/api/server/httputils"
+	"github.com/docker/docker/errors"
+	"github.com/docker/libtrust"
+	"golang.org/x/net/context"
+)
+
+const (
+	signatureHeader     = "X-Docker-Signature"
+	signatureDateHeader = "X-Docker-Date"
+	contentSHA256Header = "X-Docker-Content-Sha256"
+	unsignedPayload     = "UNSIGNED-PAYLOAD"
+
+	// maxSignatureSkew is the maximum difference between the signature
+	// date of a request and the daemon's clock.
+	maxSignatureSkew = 5 * time.Minute
+	// maxSignedBodySize limits the size of the bodies buffered to verify
+	// their hash.
+	maxSignedBodySize = 32 << 20
+)
+
+// SignatureKeys holds the keys trusted to sign API requests.
+type SignatureKeys struct {
+	// HMAC holds the shared secrets by key ID.
+	HMAC map[string][]byte
+	// Public holds the public keys by key ID.
+	Public map[string]libtrust.PublicKey
+}
+
+// LoadSignatureKeys loads the keys trusted to sign API requests. hmacFile
+// holds a keyid:secret pair per line, and authorizedKeysFile is a libtrust
+// key set in JSON or PEM format. Either file name may be empty.
+func LoadSignatureKeys(hmacFile, authorizedKeysFile string) (*SignatureKeys, error) {
+	keys := &SignatureKeys{
+		HMAC:   make(map[string][]byte),
+		Public: make(map[string]libtrust.PublicKey),
+	}
+	if hmacFile != "" {
+		f, err := os.Open(hmacFile)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		scanner := bufio.NewScanner(f)
+		for line := 1; scanner.Scan(); line++ {
+			text := strings.TrimSpace(scanner.Text())
+			if text == "" || strings.HasPrefix(text, "#") {
+				continue
+			}
+			parts := strings.SplitN(text, ":", 2)
+			if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+				return nil, fmt.Errorf("invalid key at line %d of %s, expected keyid:secret", line, hmacFile)
+			}
+			keys.HMAC[parts[0]] = []byte(parts[1])
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+	}
+	if authorizedKeysFile != "" {
+		publicKeys, err := libtrust.LoadKeySetFile(authorizedKeysFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load authorized keys from %s: %v", authorizedKeysFile, err)
+		}
+		for _, key := range publicKeys {
+			keys.Public[key.KeyID()] = key
+		}
+	}
+	return keys, nil
+}
+
+// NewSignatureMiddleware creates a middleware rejecting the requests that
+// are not signed by one of the given keys.
+func NewSignatureMiddleware(keys *SignatureKeys) Middleware {
+	return func(handler httputils.APIFunc) httputils.APIFunc {
+		return func(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+			if err := keys.verifyRequest(r, time.Now()); err != nil {
+				logrus.Errorf("Signature verification for %s %s failed: %v", r.Method, r.RequestURI, err)
+				return errors.NewErrorWithStatusCode(err, http.StatusUnauthorized)
+			}
+			return handler(ctx, w, r, vars)
+		}
+	}
+}
+
+// verifyRequest checks the signature, date and body hash of a request.
+// The body is buffered to verify its hash, unless it was left unsigned.
+func (keys *SignatureKeys) verifyRequest(r *http.Request, now time.Time) error {
+	header := r.Header.Get(signatureHeader)
+	if header == "" {
+		return fmt.Errorf("request is not signed")
+	}
+	params := parseSignatureHeader(header)
+	keyID, alg := params["keyid"], params["alg"]
+	signature, err := base64.RawURLEncoding.DecodeString(params["sig"])
+	if err != nil || len(signature) == 0 {
+		return fmt.Errorf("invalid request signature")
+	}
+
+	date := r.Header.Get(signatureDateHeader)
+	signedAt, err := time.Parse(time.RFC3339, date)
+	if err != nil {
+		return fmt.Errorf("invalid signature date %q", date)
+	}
+	if skew := now.Sub(signedAt); skew > maxSignatureSkew || skew < -maxSignatureSkew {
+		return fmt.Errorf("signature date %s is too far from the daemon's clock", date)
+	}
+
+	contentHash := r.Header.Get(contentSHA256Header)
+	if contentHash != unsignedPayload {
+		body, err := ioutil.ReadAll(io.LimitReader(r.Body, maxSignedBodySize+1))
+		if err != nil {
+			return err
+		}
+		if len(body) > maxSignedBodySize {
+			return fmt.Errorf("signed request body is larger than %d bytes", maxSignedBodySize)
+		}
+		sum := sha256.Sum256(body)
+		if !hmac.Equal([]byte(hex.EncodeToString(sum[:])), []byte(contentHash)) {
+			return fmt.Errorf("request body doesn't match its signed hash")
+		}
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+
+	payload := []byte(strings.Join([]string{r.Method, r.URL.RequestURI(), date, contentHash}, "\n"))
+	if secret, ok := keys.HMAC[keyID]; ok {
+		if alg != "HS256" {
+			return fmt.Errorf("unsupported signature algorithm %q for key %s", alg, keyID)
+		}
+		mac := hmac.New(sha256.New, secret)
+		mac.Write(payload)
+		if !hmac.Equal(mac.Sum(nil), signature) {
+			return fmt.Errorf("invalid signature for key %s", keyID)
+		}
+		return nil
+	}
+	if key, ok := keys.Public[keyID]; ok {
+		if err := key.Verify(bytes.NewReader(payload), alg, signature); err != nil {
+			return fmt.Errorf("invalid signature for key %s: %v", keyID, err)
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown signing key %q", keyID)
+}
+
+// parseSignatureHeader parses the comma separated key="value" pairs
+// of a signature header.
+func parseSignatureHeader(header string) map[string]string {
+	params := make(map[string]string)
+	for _, field := range strings.Split(header, ",") {
+		parts := strings.SplitN(strings.TrimSpace(field), "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		params[strings.ToLower(parts[0])] = strings.Trim(parts[1], `"`)
+	}
+	return params
+}
@@ -0,0 +1,114 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker/engine-api/client"
+	"github.com/docker/libtrust"
+	"golang.org/x/net/context"
+)
+
+func signTestRequest(t *testing.T, req *http.Request, body string, date time.Time, keyID string, sign func([]byte) ([]byte, string)) {
+	sum := sha256.Sum256([]byte(body))
+	contentHash := hex.EncodeToString(sum[:])
+	signedAt := date.UTC().Format(time.RFC3339)
+	signature, alg := sign(client.SignaturePayload(req.Method, req.URL.RequestURI(), signedAt, contentHash))
+	req.Header.Set(client.SignatureDateHeader, signedAt)
+	req.Header.Set(client.ContentSHA256Header, contentHash)
+	req.Header.Set(client.SignatureHeader, fmt.Sprintf("keyid=%q, alg=%q, sig=%q", keyID, alg, base64.RawURLEncoding.EncodeToString(signature)))
+}
+
+func hmacSign(secret string) func([]byte) ([]byte, string) {
+	return func(payload []byte) ([]byte, string) {
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write(payload)
+		return mac.Sum(nil), "HS256"
+	}
+}
+
+func TestSignatureMiddleware(t *testing.T) {
+	trustKey, err := libtrust.GenerateECP256PrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := &SignatureKeys{
+		HMAC:   map[string][]byte{"ci": []byte("s3cr3t")},
+		Public: map[string]libtrust.PublicKey{trustKey.KeyID(): trustKey.PublicKey()},
+	}
+
+	var received string
+	handler := func(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+		b, err := ioutil.ReadAll(r.Body)
+		received = string(b)
+		return err
+	}
+	h := NewSignatureMiddleware(keys)(handler)
+
+	body := `{"Image":"busybox"}`
+	now := time.Now()
+	cases := []struct {
+		name    string
+		prepare func(req *http.Request)
+		valid   bool
+	}{
+		{"hmac", func(req *http.Request) {
+			signTestRequest(t, req, body, now, "ci", hmacSign("s3cr3t"))
+		}, true},
+		{"public key", func(req *http.Request) {
+			signTestRequest(t, req, body, now, trustKey.KeyID(), func(payload []byte) ([]byte, string) {
+				signature, alg, err := trustKey.Sign(bytes.NewReader(payload), crypto.SHA256)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return signature, alg
+			})
+		}, true},
+		{"unsigned", func(req *http.Request) {}, false},
+		{"wrong secret", func(req *http.Request) {
+			signTestRequest(t, req, body, now, "ci", hmacSign("guess"))
+		}, false},
+		{"unknown key", func(req *http.Request) {
+			signTestRequest(t, req, body, now, "other", hmacSign("s3cr3t"))
+		}, false},
+		{"stale date", func(req *http.Request) {
+			signTestRequest(t, req, body, now.Add(-time.Hour), "ci", hmacSign("s3cr3t"))
+		}, false},
+		{"tampered body", func(req *http.Request) {
+			signTestRequest(t, req, `{"Image":"evil"}`, now, "ci", hmacSign("s3cr3t"))
+		}, false},
+	}
+
+	for _, c := range cases {
+		received = ""
+		req, _ := http.NewRequest("POST", "/v1.23/containers/create?name=web", strings.NewReader(body))
+		c.prepare(req)
+		err := h(context.Background(), httptest.NewRecorder(), req, map[string]string{})
+		if c.valid {
+			if err != nil {
+				t.Fatalf("%s: expected the request to be accepted, got %v", c.name, err)
+			}
+			if received != body {
+				t.Fatalf("%s: expected the handler to read %q, got %q", c.name, body, received)
+			}
+			continue
+		}
+		if err == nil {
+			t.Fatalf("%s: expected the request to be rejected", c.name)
+		}
+		if received != "" {
+			t.Fatalf("%s: the handler must not be called", c.name)
+		}
+	}
+}
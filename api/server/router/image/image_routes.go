@@ -61,6 +61,7 @@ func (s *imageRouter) postCommit(ctx context.Context, w http.ResponseWriter, r *
 		Comment:      r.Form.Get("comment"),
 		Config:       newConfig,
 		MergeConfigs: true,
+		Squash:       httputils.BoolValue(r, "squash"),
 	}
 
 	imgID, err := s.backend.Commit(cname, commitCfg)
@@ -80,18 +81,28 @@ func (s *imageRouter) postImagesCreate(ctx context.Context, w http.ResponseWrite
 	}
 
 	var (
-		image   = r.Form.Get("fromImage")
-		repo    = r.Form.Get("repo")
-		tag     = r.Form.Get("tag")
-		message = r.Form.Get("message")
-		err     error
-		output  = ioutils.NewWriteFlusher(w)
+		image    = r.Form.Get("fromImage")
+		repo     = r.Form.Get("repo")
+		tag      = r.Form.Get("tag")
+		platform = r.Form.Get("platform")
+		message  = r.Form.Get("message")
+		err      error
+		output   = ioutils.NewWriteFlusher(w)
 	)
 	defer output.Close()
 
 	w.Header().Set("Content-Type", "application/json")
 
+	// progress is where the progress of the operation is written, in the
+	// format the client asked for.
+	var progress io.Writer = output
+
 	if image != "" { //pull
+		if strings.Contains(r.Header.Get("Accept"), types.MediaTypeProgressV2) {
+			w.Header().Set("Content-Type", types.MediaTypeProgressV2)
+			progress = newProgressV2Writer(output)
+		}
+
 		// Special case: "pull -a" may send an image name with a
 		// trailing :. This is ugly, but let's not break API
 		// compatibility.
@@ -129,7 +140,7 @@ func (s *imageRouter) postImagesCreate(ctx context.Context, w http.ResponseWrite
 					}
 				}
 
-				err = s.backend.PullImage(ref, metaHeaders, authConfig, output)
+				err = s.backend.PullImage(ref, platform, metaHeaders, authConfig, progress)
 			}
 		}
 		// Check the error from pulling an image to make sure the request
@@ -177,7 +188,7 @@ func (s *imageRouter) postImagesCreate(ctx context.Context, w http.ResponseWrite
 			return err
 		}
 		sf := streamformatter.NewJSONStreamFormatter()
-		output.Write(sf.FormatError(err))
+		progress.Write(sf.FormatError(err))
 	}
 
 	return nil
@@ -238,6 +249,40 @@ func (s *imageRouter) postImagesPush(ctx context.Context, w http.ResponseWriter,
 	return nil
 }
 
+func (s *imageRouter) postImagesVerify(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	metaHeaders := map[string][]string{}
+	for k, v := range r.Header {
+		if strings.HasPrefix(k, "X-Meta-") {
+			metaHeaders[k] = v
+		}
+	}
+	if err := httputils.ParseForm(r); err != nil {
+		return err
+	}
+	authConfig := &types.AuthConfig{}
+	if authEncoded := r.Header.Get("X-Registry-Auth"); authEncoded != "" {
+		authJSON := base64.NewDecoder(base64.URLEncoding, strings.NewReader(authEncoded))
+		if err := json.NewDecoder(authJSON).Decode(authConfig); err != nil {
+			// the credentials are only needed to pull the image again
+			authConfig = &types.AuthConfig{}
+		}
+	}
+
+	output := ioutils.NewWriteFlusher(w)
+	defer output.Close()
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if err := s.backend.VerifyImage(vars["name"], httputils.BoolValue(r, "repull"), metaHeaders, authConfig, output); err != nil {
+		if !output.Flushed() {
+			return err
+		}
+		sf := streamformatter.NewJSONStreamFormatter()
+		output.Write(sf.FormatError(err))
+	}
+	return nil
+}
+
 func (s *imageRouter) getImagesGet(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
 	if err := httputils.ParseForm(r); err != nil {
 		return err
@@ -254,7 +299,7 @@ func (s *imageRouter) getImagesGet(ctx context.Context, w http.ResponseWriter, r
 		names = r.Form["names"]
 	}
 
-	if err := s.backend.ExportImage(names, output); err != nil {
+	if err := s.backend.ExportImage(names, r.Form.Get("format"), output); err != nil {
 		if !output.Flushed() {
 			return err
 		}
@@ -301,7 +346,7 @@ func (s *imageRouter) getImagesByName(ctx context.Context, w http.ResponseWriter
 		return err
 	}
 
-	return httputils.WriteJSON(w, http.StatusOK, imageInspect)
+	return httputils.WriteJSONWithETag(w, r, imageInspect)
 }
 
 func (s *imageRouter) getImagesJSON(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
@@ -0,0 +1,130 @@
+package image
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/pkg/jsonmessage"
+	"github.com/docker/engine-api/types"
+)
+
+// layerStates maps the progress actions of the pullers to the states of
+// the v2 progress format.
+var layerStates = map[string]types.LayerState{
+	"Pulling fs layer":   types.LayerStatePending,
+	"Waiting":            types.LayerStateWaiting,
+	"Downloading":        types.LayerStateDownloading,
+	"Verifying Checksum": types.LayerStateVerifying,
+	"Download complete":  types.LayerStateDownloaded,
+	"Extracting":         types.LayerStateExtracting,
+	"Pull complete":      types.LayerStateComplete,
+	"Already exists":     types.LayerStateExists,
+}
+
+func layerState(action string) types.LayerState {
+	if state, ok := layerStates[action]; ok {
+		return state
+	}
+	if strings.HasPrefix(action, "Retrying in") {
+		return types.LayerStateRetrying
+	}
+	return types.LayerStateUnknown
+}
+
+type layerStateKey struct {
+	id    string
+	state types.LayerState
+}
+
+// progressV2Writer converts the JSON messages of a pull, written one per
+// line, to the v2 progress format.
+type progressV2Writer struct {
+	out io.Writer
+	buf []byte
+	// started holds the time at which every layer entered its current
+	// state, to estimate the time left in the state.
+	started map[layerStateKey]time.Time
+	now     func() time.Time
+}
+
+func newProgressV2Writer(out io.Writer) *progressV2Writer {
+	return &progressV2Writer{
+		out:     out,
+		started: make(map[layerStateKey]time.Time),
+		now:     time.Now,
+	}
+}
+
+func (w *progressV2Writer) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			return len(p), nil
+		}
+		line := w.buf[:i]
+		w.buf = w.buf[i+1:]
+		if err := w.convert(line); err != nil {
+			return len(p), err
+		}
+	}
+}
+
+func (w *progressV2Writer) convert(line []byte) error {
+	var jm jsonmessage.JSONMessage
+	if err := json.Unmarshal(line, &jm); err != nil {
+		// Not a JSON message, pass it through.
+		_, err := w.out.Write(append(line, '\n'))
+		return err
+	}
+
+	var m types.ProgressMessageV2
+	switch {
+	case jm.Error != nil:
+		m.Error = &types.ProgressError{Code: jm.Error.Code, Message: jm.Error.Message}
+	case jm.ErrorMessage != "":
+		m.Error = &types.ProgressError{Message: jm.ErrorMessage}
+	case jm.Aux != nil:
+		return nil
+	case jm.Progress != nil && jm.ID != "":
+		m.ID = jm.ID
+		m.State = layerState(jm.Status)
+		m.Action = jm.Status
+		m.Current = jm.Progress.Current
+		m.Total = jm.Progress.Total
+		m.ETA = w.eta(m)
+	default:
+		if jm.ID == "" && jm.Status == "" {
+			return nil
+		}
+		m.ID = jm.ID
+		m.Status = jm.Status
+	}
+
+	b, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	_, err = w.out.Write(append(b, '\n'))
+	return err
+}
+
+// eta estimates the seconds left in the current state of a layer from the
+// rate at which it progressed since it entered the state.
+func (w *progressV2Writer) eta(m types.ProgressMessageV2) int64 {
+	key := layerStateKey{m.ID, m.State}
+	now := w.now()
+	start, ok := w.started[key]
+	if !ok {
+		w.started[key] = now
+		return 0
+	}
+	if m.Current <= 0 || m.Total <= m.Current {
+		return 0
+	}
+	elapsed := now.Sub(start)
+	return int64(elapsed.Seconds() * float64(m.Total-m.Current) / float64(m.Current))
+}
@@ -0,0 +1,59 @@
+package image
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/pkg/progress"
+	"github.com/docker/docker/pkg/streamformatter"
+	"github.com/docker/engine-api/types"
+)
+
+func TestProgressV2Writer(t *testing.T) {
+	var buf bytes.Buffer
+	w := newProgressV2Writer(&buf)
+	now := time.Date(2016, 4, 1, 12, 0, 0, 0, time.UTC)
+	w.now = func() time.Time { return now }
+
+	sf := streamformatter.NewJSONStreamFormatter()
+	out := sf.NewProgressOutput(w, false)
+	out.WriteProgress(progress.Progress{Message: "Pulling from library/busybox", ID: "latest"})
+	progress.Update(out, "abc", "Pulling fs layer")
+	out.WriteProgress(progress.Progress{ID: "abc", Action: "Downloading", Current: 0, Total: 400})
+	now = now.Add(10 * time.Second)
+	out.WriteProgress(progress.Progress{ID: "abc", Action: "Downloading", Current: 100, Total: 400})
+	progress.Update(out, "abc", "Retrying in 5 seconds")
+	out.WriteProgress(progress.Progress{ID: "abc", Action: "Downloading", Aux: "aux"})
+	w.Write(sf.FormatError(errors.New("boom")))
+
+	expected := []types.ProgressMessageV2{
+		{ID: "latest", Status: "Pulling from library/busybox"},
+		{ID: "abc", State: types.LayerStatePending, Action: "Pulling fs layer"},
+		{ID: "abc", State: types.LayerStateDownloading, Action: "Downloading", Total: 400},
+		{ID: "abc", State: types.LayerStateDownloading, Action: "Downloading", Current: 100, Total: 400, ETA: 30},
+		{ID: "abc", State: types.LayerStateRetrying, Action: "Retrying in 5 seconds"},
+		{Error: &types.ProgressError{Message: "boom"}},
+	}
+	dec := json.NewDecoder(&buf)
+	for i, e := range expected {
+		var m types.ProgressMessageV2
+		if err := dec.Decode(&m); err != nil {
+			t.Fatalf("message %d: %v", i, err)
+		}
+		if m.Error != nil && e.Error != nil {
+			if *m.Error != *e.Error {
+				t.Fatalf("message %d: expected error %+v, got %+v", i, e.Error, m.Error)
+			}
+			m.Error, e.Error = nil, nil
+		}
+		if m != e {
+			t.Fatalf("message %d: expected %+v, got %+v", i, e, m)
+		}
+	}
+	if dec.More() {
+		t.Fatal("expected the aux message to be dropped")
+	}
+}
@@ -32,14 +32,14 @@ func (s *systemRouter) getInfo(ctx context.Context, w http.ResponseWriter, r *ht
 		return err
 	}
 
-	return httputils.WriteJSON(w, http.StatusOK, info)
+	return httputils.WriteJSONWithETag(w, r, info)
 }
 
 func (s *systemRouter) getVersion(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
 	info := s.backend.SystemVersion()
 	info.APIVersion = api.DefaultVersion.String()
 
-	return httputils.WriteJSON(w, http.StatusOK, info)
+	return httputils.WriteJSONWithETag(w, r, info)
 }
 
 func (s *systemRouter) getEvents(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
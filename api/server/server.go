@@ -8,6 +8,7 @@ import (
 
 	"github.com/Sirupsen/logrus"
 	"github.com/docker/docker/api/server/httputils"
+	"github.com/docker/docker/api/server/middleware"
 	"github.com/docker/docker/api/server/router"
 	"github.com/docker/docker/pkg/authorization"
 	"github.com/gorilla/mux"
@@ -27,6 +28,8 @@ type Config struct {
 	Version                  string
 	SocketGroup              string
 	TLSConfig                *tls.Config
+	EnableHTTP2              bool
+	SignatureKeys            *middleware.SignatureKeys
 }
 
 // Server contains instance details for the server
@@ -55,6 +58,11 @@ func (s *Server) Accept(addr string, listeners ...net.Listener) {
 			},
 			l: listener,
 		}
+		if s.cfg.EnableHTTP2 {
+			if err := configureHTTP2(httpServer.srv); err != nil {
+				logrus.Warnf("HTTP/2 disabled on %s: %v", addr, err)
+			}
+		}
 		s.servers = append(s.servers, httpServer)
 	}
 }
@@ -118,6 +118,9 @@ type Backend interface {
 	ContainerRm(name string, config *types.ContainerRmConfig) error
 	// Commit creates a new Docker image from an existing Docker container.
 	Commit(string, *types.ContainerCommitConfig) (string, error)
+	// SquashImage collapses the layers of an image on top of its parent
+	// image `parent` into one, and returns the ID of the new image.
+	SquashImage(id, parent string) (string, error)
 	// Kill stops the container execution abruptly.
 	ContainerKill(containerID string, sig uint64) error
 	// Start starts a new container
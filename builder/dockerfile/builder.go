@@ -60,6 +60,7 @@ type Builder struct {
 	flags            *BFlags
 	tmpContainers    map[string]struct{}
 	image            string // imageID
+	baseImage        string // imageID of the last FROM, on top of which a build is squashed
 	noBaseImage      bool
 	maintainer       string
 	cmdSet           bool
@@ -249,6 +250,16 @@ func (b *Builder) build(config *types.ImageBuildOptions, context builder.Context
 		return "", fmt.Errorf("No image was generated. Is your Dockerfile empty?")
 	}
 
+	if b.options.Squash {
+		squashedID, err := b.docker.SquashImage(b.image, b.baseImage)
+		if err != nil {
+			return "", err
+		}
+		b.image = squashedID
+		shortImgID = stringid.TruncateID(b.image)
+		fmt.Fprintf(b.Stdout, "Squashed the layers of the build into %s\n", shortImgID)
+	}
+
 	for _, rt := range repoAndTags {
 		if err := b.docker.TagImage(rt, b.image); err != nil {
 			return "", err
@@ -401,6 +401,7 @@ func (b *Builder) processImageFrom(img builder.Image) error {
 			b.runConfig = img.RunConfig()
 		}
 	}
+	b.baseImage = b.image
 
 	// Check to see if we have a default PATH, note that windows won't
 	// have one as its set by HCS
@@ -52,10 +52,13 @@ func (cli *Cli) command(args ...string) (func(...string) error, error) {
 		}
 		camelArgs := make([]string, len(args))
 		for i, s := range args {
-			if len(s) == 0 {
-				return nil, errors.New("empty command")
+			// Hyphenated commands like registry-bundle map to CmdRegistryBundle.
+			for _, word := range strings.Split(s, "-") {
+				if len(word) == 0 {
+					return nil, errors.New("empty command")
+				}
+				camelArgs[i] += strings.ToUpper(word[:1]) + strings.ToLower(word[1:])
 			}
-			camelArgs[i] = strings.ToUpper(s[:1]) + strings.ToLower(s[1:])
 		}
 		methodName := "Cmd" + strings.Join(camelArgs, "")
 		method := reflect.ValueOf(c).MethodByName(methodName)
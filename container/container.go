@@ -62,6 +62,7 @@ type CommonContainer struct {
 	Args            []string
 	Config          *containertypes.Config
 	ImageID         image.ID `json:"Image"`
+	ResolvedImage   string   `json:",omitempty"` // Reference by digest of the image, if it was created from one or resolved to one.
 	NetworkSettings *network.Settings
 	LogPath         string
 	Name            string
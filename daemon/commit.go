@@ -7,6 +7,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/Sirupsen/logrus"
 	"github.com/docker/docker/container"
 	"github.com/docker/docker/dockerversion"
 	"github.com/docker/docker/image"
@@ -193,6 +194,20 @@ func (daemon *Daemon) Commit(name string, c *types.ContainerCommitConfig) (strin
 		}
 	}
 
+	if c.Squash {
+		squashedID, err := daemon.SquashImage(id.String(), "")
+		if err != nil {
+			return "", err
+		}
+		if squashedID != id.String() {
+			// The image with all the layers is not needed anymore.
+			if _, err := daemon.imageStore.Delete(id); err != nil {
+				logrus.Errorf("Failed to delete the image squashed by the commit of %s: %v", container.ID, err)
+			}
+			id = image.ID(squashedID)
+		}
+	}
+
 	if c.Repo != "" {
 		newTag, err := reference.WithName(c.Repo) // todo: should move this to API layer
 		if err != nil {
@@ -10,6 +10,8 @@ import (
 	"sync"
 
 	"github.com/Sirupsen/logrus"
+	"github.com/docker/docker/distribution/trust"
+	"github.com/docker/docker/distribution/xfer"
 	"github.com/docker/docker/opts"
 	"github.com/docker/docker/pkg/discovery"
 	flag "github.com/docker/docker/pkg/mflag"
@@ -27,8 +29,12 @@ const (
 // Use this to differentiate these options
 // with others like the ones in CommonTLSOptions.
 var flatOptions = map[string]bool{
-	"cluster-store-opts": true,
-	"log-opts":           true,
+	"cluster-store-opts":       true,
+	"credential-helpers":       true,
+	"log-opts":                 true,
+	"registry-transfer-limits": true,
+	"content-trust-pins":       true,
+	"registry-tls":             true,
 }
 
 // LogConfig represents the default log configuration.
@@ -92,6 +98,59 @@ type CommonConfig struct {
 	TLS       bool     `json:"tls,omitempty"`
 	TLSVerify bool     `json:"tlsverify,omitempty"`
 
+	// APIHTTP2 enables HTTP/2 on the API listeners, in addition to HTTP/1.1.
+	APIHTTP2 bool `json:"api-http2,omitempty"`
+
+	// APIHMACKeys and APIAuthorizedKeys are the files holding the keys
+	// trusted to sign API requests. When either is set, unsigned requests
+	// are rejected.
+	APIHMACKeys       string `json:"api-hmac-keys,omitempty"`
+	APIAuthorizedKeys string `json:"api-authorized-keys,omitempty"`
+
+	// MaxConcurrentDownloads and MaxConcurrentUploads are the maximum
+	// numbers of layers transferred at a time from and to the registries
+	// without their own limits in RegistryTransferLimits.
+	MaxConcurrentDownloads int `json:"max-concurrent-downloads,omitempty"`
+	MaxConcurrentUploads   int `json:"max-concurrent-uploads,omitempty"`
+
+	// RegistryTransferLimits maps registry hosts to their transfer limits,
+	// written as comma separated key=value pairs.
+	RegistryTransferLimits map[string]string `json:"registry-transfer-limits,omitempty"`
+
+	// RegistryCache serves a pull-through cache of Docker Hub on
+	// RegistryCacheAddr, for the other daemons of the network to use as a
+	// registry mirror.
+	RegistryCache     bool   `json:"registry-cache,omitempty"`
+	RegistryCacheAddr string `json:"registry-cache-addr,omitempty"`
+
+	// ContentTrust requires the images pulled and run by the daemon to be
+	// signed in the trust data of their repositories, on ContentTrustServer
+	// or on the default Notary server of their registries.
+	ContentTrust       bool   `json:"content-trust,omitempty"`
+	ContentTrustServer string `json:"content-trust-server,omitempty"`
+
+	// ContentTrustPins maps repositories to the IDs of the root keys their
+	// trust data must be signed with, written as comma separated key IDs.
+	ContentTrustPins map[string]string `json:"content-trust-pins,omitempty"`
+
+	// ImageReferencePolicy is the policy of the containers created from
+	// images referenced by tag, which can move to other images: allow,
+	// warn or reject. ResolveImageDigests records the digests of the pulls
+	// by tag, and pins these containers to the digest of their tag, which
+	// satisfies the policy.
+	ImageReferencePolicy string `json:"image-reference-policy,omitempty"`
+	ResolveImageDigests  bool   `json:"resolve-image-digests,omitempty"`
+
+	// ImageGCInterval is the interval between the runs of the image
+	// garbage collector, disabled when empty. It collects the images not
+	// used by a container for ImageGCKeepDays, and the least recently used
+	// images while the images take more than ImageGCMaxStorage. The images
+	// of the references in ImageGCProtect are never collected.
+	ImageGCInterval   string   `json:"image-gc-interval,omitempty"`
+	ImageGCKeepDays   int      `json:"image-gc-keep-days,omitempty"`
+	ImageGCMaxStorage string   `json:"image-gc-max-storage,omitempty"`
+	ImageGCProtect    []string `json:"image-gc-protect,omitempty"`
+
 	// Embedded structs that allow config
 	// deserialization without the full struct.
 	CommonTLSOptions
@@ -120,6 +179,9 @@ func (config *Config) InstallCommonFlags(cmd *flag.FlagSet, usageFn func(string)
 	cmd.StringVar(&config.GraphDriver, []string{"s", "-storage-driver"}, "", usageFn("Storage driver to use"))
 	cmd.IntVar(&config.Mtu, []string{"#mtu", "-mtu"}, 0, usageFn("Set the containers network MTU"))
 	cmd.BoolVar(&config.RawLogs, []string{"-raw-logs"}, false, usageFn("Full timestamps without ANSI coloring"))
+	cmd.BoolVar(&config.APIHTTP2, []string{"-api-http2"}, false, usageFn("Accept HTTP/2 connections on the API listeners"))
+	cmd.StringVar(&config.APIHMACKeys, []string{"-api-hmac-keys"}, "", usageFn("File of keyid:secret pairs trusted to sign API requests"))
+	cmd.StringVar(&config.APIAuthorizedKeys, []string{"-api-authorized-keys"}, "", usageFn("Public key set trusted to sign API requests"))
 	// FIXME: why the inconsistency between "hosts" and "sockets"?
 	cmd.Var(opts.NewListOptsRef(&config.DNS, opts.ValidateIPAddress), []string{"#dns", "-dns"}, usageFn("DNS server to use"))
 	cmd.Var(opts.NewNamedListOptsRef("dns-opts", &config.DNSOptions, nil), []string{"-dns-opt"}, usageFn("DNS options to use"))
@@ -130,6 +192,20 @@ func (config *Config) InstallCommonFlags(cmd *flag.FlagSet, usageFn func(string)
 	cmd.StringVar(&config.ClusterAdvertise, []string{"-cluster-advertise"}, "", usageFn("Address or interface name to advertise"))
 	cmd.StringVar(&config.ClusterStore, []string{"-cluster-store"}, "", usageFn("Set the cluster store"))
 	cmd.Var(opts.NewNamedMapOpts("cluster-store-opts", config.ClusterOpts, nil), []string{"-cluster-store-opt"}, usageFn("Set cluster store options"))
+	cmd.IntVar(&config.MaxConcurrentDownloads, []string{"-max-concurrent-downloads"}, defaultMaxConcurrentDownloads, usageFn("Set the max concurrent downloads for each pull"))
+	cmd.IntVar(&config.MaxConcurrentUploads, []string{"-max-concurrent-uploads"}, defaultMaxConcurrentUploads, usageFn("Set the max concurrent uploads for each push"))
+	cmd.Var(opts.NewNamedMapOpts("registry-transfer-limits", config.RegistryTransferLimits, ValidateRegistryTransferLimit), []string{"-registry-transfer-limit"}, usageFn("Set the transfer limits of a registry (host=key=value,...)"))
+	cmd.BoolVar(&config.RegistryCache, []string{"-registry-cache"}, false, usageFn("Serve a pull-through cache of Docker Hub"))
+	cmd.StringVar(&config.RegistryCacheAddr, []string{"-registry-cache-addr"}, defaultRegistryCacheAddr, usageFn("Address to serve the registry cache on"))
+	cmd.BoolVar(&config.ContentTrust, []string{"-content-trust"}, false, usageFn("Require the images pulled and run to be signed"))
+	cmd.StringVar(&config.ContentTrustServer, []string{"-content-trust-server"}, "", usageFn("Notary server of the trust data of all the repositories"))
+	cmd.Var(opts.NewNamedMapOpts("content-trust-pins", config.ContentTrustPins, ValidateContentTrustPin), []string{"-content-trust-pin"}, usageFn("Pin the root keys of the trust data of a repository (repository=keyID,...)"))
+	cmd.StringVar(&config.ImageReferencePolicy, []string{"-image-reference-policy"}, imageReferencePolicyAllow, usageFn("Policy of the containers of images referenced by tag (allow, warn, reject)"))
+	cmd.BoolVar(&config.ResolveImageDigests, []string{"-resolve-image-digests"}, false, usageFn("Resolve the tags of the images of new containers to digests"))
+	cmd.StringVar(&config.ImageGCInterval, []string{"-image-gc-interval"}, "", usageFn("Interval between the runs of the image garbage collector"))
+	cmd.IntVar(&config.ImageGCKeepDays, []string{"-image-gc-keep-days"}, 0, usageFn("Collect the images not used for this number of days"))
+	cmd.StringVar(&config.ImageGCMaxStorage, []string{"-image-gc-max-storage"}, "", usageFn("Collect the least recently used images above this storage size"))
+	cmd.Var(opts.NewNamedListOptsRef("image-gc-protect", &config.ImageGCProtect, nil), []string{"-image-gc-protect"}, usageFn("Protect the images of a reference from the image garbage collector"))
 }
 
 // IsValueSet returns true if a configuration value
@@ -157,6 +233,61 @@ func parseClusterAdvertiseSettings(clusterStore, clusterAdvertise string) (strin
 	return advertise, nil
 }
 
+// ValidateRegistryTransferLimit validates a host=limits pair of the
+// --registry-transfer-limit flag.
+func ValidateRegistryTransferLimit(val string) (string, error) {
+	parts := strings.SplitN(val, "=", 2)
+	if len(parts) != 2 || parts[0] == "" {
+		return "", fmt.Errorf("invalid registry transfer limit %q, expected host=key=value,...", val)
+	}
+	if _, err := xfer.ParseRegistryLimits(parts[1]); err != nil {
+		return "", err
+	}
+	return val, nil
+}
+
+// parseRegistryTransferLimits parses the transfer limits of the registries,
+// keyed by their index names.
+func parseRegistryTransferLimits(config map[string]string) (map[string]xfer.RegistryLimits, error) {
+	limits := make(map[string]xfer.RegistryLimits)
+	for host, val := range config {
+		host = strings.TrimPrefix(strings.TrimPrefix(host, "https://"), "http://")
+		host, err := registry.ValidateIndexName(strings.TrimSuffix(host, "/"))
+		if err != nil {
+			return nil, err
+		}
+		l, err := xfer.ParseRegistryLimits(val)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", host, err)
+		}
+		limits[host] = l
+	}
+	return limits, nil
+}
+
+// ValidateContentTrustPin validates a repository=keyIDs pair of the
+// --content-trust-pin flag.
+func ValidateContentTrustPin(val string) (string, error) {
+	if _, _, err := trust.ParsePin(val); err != nil {
+		return "", err
+	}
+	return val, nil
+}
+
+// parseContentTrustPins parses the root keys pinned for the repositories,
+// keyed by their full names.
+func parseContentTrustPins(config map[string]string) (map[string][]string, error) {
+	pins := make(map[string][]string)
+	for name, keyIDs := range config {
+		name, keyIDs, err := trust.ParsePin(name + "=" + keyIDs)
+		if err != nil {
+			return nil, err
+		}
+		pins[name] = keyIDs
+	}
+	return pins, nil
+}
+
 // ReloadConfiguration reads the configuration in the host and reloads the daemon and server.
 func ReloadConfiguration(configFile string, flags *flag.FlagSet, reload func(*Config)) error {
 	logrus.Infof("Got signal to reload configuration, reloading from: %s", configFile)
@@ -250,8 +381,13 @@ func getConflictFreeConfiguration(configFile string, flags *flag.FlagSet) (*Conf
 	}
 
 	reader = bytes.NewReader(b)
-	err = json.NewDecoder(reader).Decode(&config)
-	return &config, err
+	if err := json.NewDecoder(reader).Decode(&config); err != nil {
+		return nil, err
+	}
+	if err := registry.ValidateTLSSettings(config.RegistryTLS); err != nil {
+		return nil, err
+	}
+	return &config, nil
 }
 
 // configValuesSet returns the configuration values explicitly set in the file.
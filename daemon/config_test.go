@@ -85,6 +85,26 @@ func TestParseClusterAdvertiseSettings(t *testing.T) {
 	}
 }
 
+func TestParseRegistryTransferLimits(t *testing.T) {
+	limits, err := parseRegistryTransferLimits(map[string]string{
+		"https://index.docker.io":   "max-concurrent-downloads=5",
+		"registry.example.com:5000": "max-concurrent-uploads=1,max-bandwidth=1m",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if limits["docker.io"].MaxConcurrentDownloads != 5 {
+		t.Fatalf("expected the limits of index.docker.io to apply to docker.io, got %v", limits)
+	}
+	if l := limits["registry.example.com:5000"]; l.MaxConcurrentUploads != 1 || l.MaxBandwidth != 1<<20 {
+		t.Fatalf("unexpected limits for registry.example.com:5000: %+v", l)
+	}
+
+	if _, err := parseRegistryTransferLimits(map[string]string{"registry.example.com": "max-concurrent-downloads=0"}); err == nil {
+		t.Fatal("expected an error for an invalid limit")
+	}
+}
+
 func TestFindConfigurationConflicts(t *testing.T) {
 	config := map[string]interface{}{"authorization-plugins": "foobar"}
 	flags := mflag.NewFlagSet("test", mflag.ContinueOnError)
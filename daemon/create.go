@@ -2,6 +2,7 @@ package daemon
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/Sirupsen/logrus"
 	"github.com/docker/docker/container"
@@ -40,7 +41,8 @@ func (daemon *Daemon) ContainerCreate(params types.ContainerCreateConfig) (types
 		return types.ContainerCreateResponse{Warnings: warnings}, err
 	}
 
-	container, err := daemon.create(params)
+	container, imageWarnings, err := daemon.create(params)
+	warnings = append(warnings, imageWarnings...)
 	if err != nil {
 		return types.ContainerCreateResponse{Warnings: warnings}, daemon.imageNotExistToErrcode(err)
 	}
@@ -49,29 +51,37 @@ func (daemon *Daemon) ContainerCreate(params types.ContainerCreateConfig) (types
 }
 
 // Create creates a new container from the given configuration with a given name.
-func (daemon *Daemon) create(params types.ContainerCreateConfig) (retC *container.Container, retErr error) {
+func (daemon *Daemon) create(params types.ContainerCreateConfig) (retC *container.Container, warnings []string, retErr error) {
 	var (
-		container *container.Container
-		img       *image.Image
-		imgID     image.ID
-		err       error
+		container     *container.Container
+		img           *image.Image
+		imgID         image.ID
+		resolvedImage string
+		err           error
 	)
 
 	if params.Config.Image != "" {
 		img, err = daemon.GetImage(params.Config.Image)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 		imgID = img.ID()
+		if err := daemon.verifyTrustedImage(imgID); err != nil {
+			return nil, nil, err
+		}
+		if resolvedImage, warnings, err = daemon.checkImageReference(params.Config.Image, imgID); err != nil {
+			return nil, nil, err
+		}
 	}
 
 	if err := daemon.mergeAndVerifyConfig(params.Config, img); err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	if container, err = daemon.newContainer(params.Name, params.Config, imgID); err != nil {
-		return nil, err
+		return nil, nil, err
 	}
+	container.ResolvedImage = resolvedImage
 	defer func() {
 		if retErr != nil {
 			if err := daemon.ContainerRm(container.ID, &types.ContainerRmConfig{ForceRemove: true}); err != nil {
@@ -81,27 +91,27 @@ func (daemon *Daemon) create(params types.ContainerCreateConfig) (retC *containe
 	}()
 
 	if err := daemon.setSecurityOptions(container, params.HostConfig); err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	// Set RWLayer for container after mount labels have been set
 	if err := daemon.setRWLayer(container); err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	if err := daemon.Register(container); err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	rootUID, rootGID, err := idtools.GetRootUIDGID(daemon.uidMaps, daemon.gidMaps)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	if err := idtools.MkdirAs(container.Root, 0700, rootUID, rootGID); err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	if err := daemon.setHostConfig(container, params.HostConfig); err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	defer func() {
 		if retErr != nil {
@@ -112,7 +122,7 @@ func (daemon *Daemon) create(params types.ContainerCreateConfig) (retC *containe
 	}()
 
 	if err := daemon.createContainerPlatformSpecificSettings(container, params.Config, params.HostConfig); err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	var endpointsConfigs map[string]*networktypes.EndpointSettings
@@ -121,15 +131,20 @@ func (daemon *Daemon) create(params types.ContainerCreateConfig) (retC *containe
 	}
 
 	if err := daemon.updateContainerNetworkSettings(container, endpointsConfigs); err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	if err := container.ToDiskLocking(); err != nil {
 		logrus.Errorf("Error saving new container to disk: %v", err)
-		return nil, err
+		return nil, nil, err
+	}
+	if imgID != "" {
+		if err := daemon.imageStore.SetLastUsed(imgID, time.Now()); err != nil {
+			logrus.Warnf("Failed to record the use of image %s: %v", imgID, err)
+		}
 	}
 	daemon.LogContainerEvent(container, "create")
-	return container, nil
+	return container, warnings, nil
 }
 
 func (daemon *Daemon) generateSecurityOpt(ipcMode containertypes.IpcMode, pidMode containertypes.PidMode) ([]string, error) {
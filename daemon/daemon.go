@@ -41,6 +41,7 @@ import (
 	"github.com/docker/docker/daemon/network"
 	"github.com/docker/docker/distribution"
 	dmetadata "github.com/docker/docker/distribution/metadata"
+	"github.com/docker/docker/distribution/trust"
 	"github.com/docker/docker/distribution/xfer"
 	"github.com/docker/docker/dockerversion"
 	"github.com/docker/docker/image"
@@ -78,12 +79,17 @@ import (
 )
 
 const (
-	// maxDownloadConcurrency is the maximum number of downloads that
-	// may take place at a time for each pull.
-	maxDownloadConcurrency = 3
-	// maxUploadConcurrency is the maximum number of uploads that
-	// may take place at a time for each push.
-	maxUploadConcurrency = 5
+	// defaultMaxConcurrentDownloads is the default maximum number of
+	// downloads that may take place at a time for each pull.
+	defaultMaxConcurrentDownloads = 3
+	// defaultMaxConcurrentUploads is the default maximum number of uploads
+	// that may take place at a time for each push.
+	defaultMaxConcurrentUploads = 5
+	// partialDownloadsMaxAge is how long the partial layer downloads are
+	// kept to be resumed.
+	partialDownloadsMaxAge = 7 * 24 * time.Hour
+	// defaultRegistryCacheAddr is the default address of the registry cache.
+	defaultRegistryCacheAddr = "0.0.0.0:5000"
 )
 
 var (
@@ -111,6 +117,8 @@ type Daemon struct {
 	referenceStore            reference.Store
 	downloadManager           *xfer.LayerDownloadManager
 	uploadManager             *xfer.LayerUploadManager
+	downloadsDir              string
+	trustVerifier             *trust.Verifier
 	distributionMetadataStore dmetadata.Store
 	trustKey                  libtrust.PrivateKey
 	idIndex                   *truncindex.TruncIndex
@@ -711,8 +719,33 @@ func NewDaemon(config *Config, registryService *registry.Service) (daemon *Daemo
 		return nil, err
 	}
 
-	d.downloadManager = xfer.NewLayerDownloadManager(d.layerStore, maxDownloadConcurrency)
-	d.uploadManager = xfer.NewLayerUploadManager(maxUploadConcurrency)
+	if config.MaxConcurrentDownloads < 1 || config.MaxConcurrentUploads < 1 {
+		return nil, fmt.Errorf("max-concurrent-downloads and max-concurrent-uploads must be at least 1")
+	}
+	if err := validateImageReferencePolicy(config.ImageReferencePolicy); err != nil {
+		return nil, err
+	}
+	imageGC, err := parseImageGCPolicy(config)
+	if err != nil {
+		return nil, err
+	}
+	registryLimits, err := parseRegistryTransferLimits(config.RegistryTransferLimits)
+	if err != nil {
+		return nil, err
+	}
+	d.downloadManager = xfer.NewLayerDownloadManager(d.layerStore, config.MaxConcurrentDownloads, registryLimits)
+	d.uploadManager = xfer.NewLayerUploadManager(config.MaxConcurrentUploads, registryLimits)
+
+	d.downloadsDir = filepath.Join(imageRoot, "downloads")
+	if err := distribution.PrunePartialDownloads(d.downloadsDir, partialDownloadsMaxAge); err != nil {
+		logrus.Warnf("Failed to prune the partial layer downloads: %v", err)
+	}
+
+	if config.ContentTrust {
+		if d.trustVerifier, err = newTrustVerifier(config, registryService); err != nil {
+			return nil, err
+		}
+	}
 
 	ifs, err := image.NewFSStoreBackend(filepath.Join(imageRoot, "imagedb"))
 	if err != nil {
@@ -821,6 +854,10 @@ func NewDaemon(config *Config, registryService *registry.Service) (daemon *Daemo
 		return nil, err
 	}
 
+	if imageGC != nil {
+		go d.imageGC(imageGC)
+	}
+
 	return d, nil
 }
 
@@ -1007,7 +1044,19 @@ func isBrokenPipe(e error) bool {
 
 // PullImage initiates a pull operation. image is the repository name to pull, and
 // tag may be either empty, or indicate a specific tag to pull.
-func (daemon *Daemon) PullImage(ref reference.Named, metaHeaders map[string][]string, authConfig *types.AuthConfig, outStream io.Writer) error {
+func (daemon *Daemon) PullImage(ref reference.Named, platform string, metaHeaders map[string][]string, authConfig *types.AuthConfig, outStream io.Writer) error {
+	if daemon.trustVerifier != nil {
+		return daemon.pullTrustedImage(ref, platform, metaHeaders, authConfig, outStream)
+	}
+	return daemon.pullImage(ref, platform, metaHeaders, authConfig, outStream)
+}
+
+func (daemon *Daemon) pullImage(ref reference.Named, platform string, metaHeaders map[string][]string, authConfig *types.AuthConfig, outStream io.Writer) error {
+	pullPlatform, err := distribution.ParsePlatform(platform)
+	if err != nil {
+		return err
+	}
+
 	// Include a buffer so that slow client connections don't affect
 	// transfer performance.
 	progressChan := make(chan progress.Progress, 100)
@@ -1022,18 +1071,22 @@ func (daemon *Daemon) PullImage(ref reference.Named, metaHeaders map[string][]st
 	}()
 
 	imagePullConfig := &distribution.ImagePullConfig{
-		MetaHeaders:      metaHeaders,
-		AuthConfig:       authConfig,
-		ProgressOutput:   progress.ChanOutput(progressChan),
-		RegistryService:  daemon.RegistryService,
-		ImageEventLogger: daemon.LogImageEvent,
-		MetadataStore:    daemon.distributionMetadataStore,
-		ImageStore:       daemon.imageStore,
-		ReferenceStore:   daemon.referenceStore,
-		DownloadManager:  daemon.downloadManager,
-	}
-
-	err := distribution.Pull(ctx, ref, imagePullConfig)
+		MetaHeaders:          metaHeaders,
+		AuthConfig:           authConfig,
+		Platform:             pullPlatform,
+		ProgressOutput:       progress.ChanOutput(progressChan),
+		RegistryService:      daemon.RegistryService,
+		RecordDigests:        daemon.configStore.ResolveImageDigests,
+		ImageEventLogger:     daemon.LogImageEvent,
+		RateLimitEventLogger: daemon.LogRateLimitEvent,
+		MetadataStore:        daemon.distributionMetadataStore,
+		ImageStore:           daemon.imageStore,
+		ReferenceStore:       daemon.referenceStore,
+		DownloadManager:      daemon.downloadManager,
+		DownloadsDir:         daemon.downloadsDir,
+	}
+
+	err = distribution.Pull(ctx, ref, imagePullConfig)
 	close(progressChan)
 	<-writesDone
 	return err
@@ -1062,7 +1115,7 @@ func (daemon *Daemon) PullOnBuild(name string, authConfigs map[string]types.Auth
 		pullRegistryAuth = &resolvedConfig
 	}
 
-	if err := daemon.PullImage(ref, nil, pullRegistryAuth, output); err != nil {
+	if err := daemon.PullImage(ref, "", nil, pullRegistryAuth, output); err != nil {
 		return nil, err
 	}
 	return daemon.GetImage(name)
@@ -1071,11 +1124,19 @@ func (daemon *Daemon) PullOnBuild(name string, authConfigs map[string]types.Auth
 // ExportImage exports a list of images to the given output stream. The
 // exported images are archived into a tar when written to the output
 // stream. All images with the given tag and all versions containing
-// the same tag are exported. names is the set of tags to export, and
-// outStream is the writer which the images are written to.
-func (daemon *Daemon) ExportImage(names []string, outStream io.Writer) error {
+// the same tag are exported. names is the set of tags to export, format
+// is the layout of the archive, either "docker" or "oci", and outStream is
+// the writer which the images are written to.
+func (daemon *Daemon) ExportImage(names []string, format string, outStream io.Writer) error {
 	imageExporter := tarexport.NewTarExporter(daemon.imageStore, daemon.layerStore, daemon.referenceStore)
-	return imageExporter.Save(names, outStream)
+	switch format {
+	case "", "docker":
+		return imageExporter.Save(names, outStream)
+	case "oci":
+		return imageExporter.SaveOCI(names, outStream)
+	default:
+		return fmt.Errorf("invalid image archive format %q, expected docker or oci", format)
+	}
 }
 
 // PushImage initiates a push operation on the repository named localName.
@@ -1314,6 +1375,9 @@ func (daemon *Daemon) GetImageOnBuild(name string) (builder.Image, error) {
 	if err != nil {
 		return nil, err
 	}
+	if err := daemon.verifyTrustedImage(img.ID()); err != nil {
+		return nil, err
+	}
 	return img, nil
 }
 
@@ -1,9 +1,11 @@
 package daemon
 
 import (
+	"strconv"
 	"strings"
 
 	"github.com/docker/docker/container"
+	"github.com/docker/docker/registry"
 	"github.com/docker/engine-api/types/events"
 	"github.com/docker/libnetwork"
 )
@@ -52,6 +54,23 @@ func (daemon *Daemon) LogImageEventWithAttributes(imageID, refName, action strin
 	daemon.EventsService.Log(action, events.ImageEventType, actor)
 }
 
+// LogRateLimitEvent generates an event reporting the rate limit of the
+// registry of the repository being pulled.
+func (daemon *Daemon) LogRateLimitEvent(name string, limit registry.RateLimit) {
+	attributes := map[string]string{}
+	if limit.Limit > 0 {
+		attributes["limit"] = strconv.Itoa(limit.Limit)
+		attributes["remaining"] = strconv.Itoa(limit.Remaining)
+	}
+	if limit.Window > 0 {
+		attributes["window"] = limit.Window.String()
+	}
+	if limit.RetryAfter > 0 {
+		attributes["retryAfter"] = limit.RetryAfter.String()
+	}
+	daemon.LogImageEventWithAttributes(name, name, "rate-limit", attributes)
+}
+
 // LogVolumeEvent generates an event related to a volume.
 func (daemon *Daemon) LogVolumeEvent(volumeID, action string, attributes map[string]string) {
 	actor := events.Actor{
@@ -0,0 +1,251 @@
+package daemon
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/docker/docker/image"
+	"github.com/docker/docker/layer"
+	"github.com/docker/docker/reference"
+	"github.com/docker/go-units"
+)
+
+const (
+	// imageGCReasonAge is the reason of the collection of the images not
+	// used for the keep duration of the policy.
+	imageGCReasonAge = "age"
+	// imageGCReasonStorage is the reason of the collection of the images
+	// collected to bring the storage of the images under its maximum.
+	imageGCReasonStorage = "storage"
+)
+
+// imageGCPolicy is the policy of the image garbage collector.
+type imageGCPolicy struct {
+	interval   time.Duration
+	keep       time.Duration
+	maxStorage int64
+	protected  []reference.Named
+}
+
+// parseImageGCPolicy parses the image-gc options of the configuration. It
+// returns nil if the image garbage collector is disabled.
+func parseImageGCPolicy(config *Config) (*imageGCPolicy, error) {
+	if config.ImageGCInterval == "" {
+		return nil, nil
+	}
+	interval, err := time.ParseDuration(config.ImageGCInterval)
+	if err != nil || interval <= 0 {
+		return nil, fmt.Errorf("invalid image-gc-interval %q, expected a positive duration like 1h", config.ImageGCInterval)
+	}
+	if config.ImageGCKeepDays < 0 {
+		return nil, fmt.Errorf("invalid image-gc-keep-days %d, expected a positive number of days", config.ImageGCKeepDays)
+	}
+	policy := &imageGCPolicy{
+		interval: interval,
+		keep:     time.Duration(config.ImageGCKeepDays) * 24 * time.Hour,
+	}
+	if config.ImageGCMaxStorage != "" {
+		if policy.maxStorage, err = units.RAMInBytes(config.ImageGCMaxStorage); err != nil || policy.maxStorage <= 0 {
+			return nil, fmt.Errorf("invalid image-gc-max-storage %q, expected a size like 20GB", config.ImageGCMaxStorage)
+		}
+	}
+	if policy.keep == 0 && policy.maxStorage == 0 {
+		return nil, fmt.Errorf("image-gc-interval requires image-gc-keep-days or image-gc-max-storage")
+	}
+	for _, val := range config.ImageGCProtect {
+		ref, err := reference.ParseNamed(val)
+		if err != nil {
+			return nil, fmt.Errorf("invalid image-gc-protect reference %q: %v", val, err)
+		}
+		policy.protected = append(policy.protected, ref)
+	}
+	return policy, nil
+}
+
+// protects returns true if ref is one of the protected references of the
+// policy, or a reference to a protected repository.
+func (policy *imageGCPolicy) protects(ref reference.Named) bool {
+	for _, p := range policy.protected {
+		if p.String() == ref.String() {
+			return true
+		}
+		if reference.IsNameOnly(p) && p.Name() == ref.Name() {
+			return true
+		}
+	}
+	return false
+}
+
+// imageGCCandidate is an image the garbage collector can collect.
+type imageGCCandidate struct {
+	id       image.ID
+	lastUsed time.Time
+	// layers holds the chain IDs of all the layers of the image.
+	layers []layer.ChainID
+}
+
+// imageGCCollection is an image planned for collection.
+type imageGCCollection struct {
+	id     image.ID
+	reason string
+	// reclaimed is the size of the layers only used by the image.
+	reclaimed int64
+}
+
+// planImageGC returns the candidates to collect, least recently used first.
+// refs holds the number of images using every layer, and sizes their sizes.
+// The candidates not used for the keep duration are collected, then the
+// least recently used ones while the layers in use take more than
+// maxStorage. refs is updated with the collected images.
+func planImageGC(candidates []imageGCCandidate, refs map[layer.ChainID]int, sizes map[layer.ChainID]int64, keep time.Duration, maxStorage int64, now time.Time) []imageGCCollection {
+	var storage int64
+	for chainID, n := range refs {
+		if n > 0 {
+			storage += sizes[chainID]
+		}
+	}
+	sort.Sort(byLastUsed(candidates))
+
+	var collections []imageGCCollection
+	for _, c := range candidates {
+		var reason string
+		switch {
+		case keep > 0 && now.Sub(c.lastUsed) > keep:
+			reason = imageGCReasonAge
+		case maxStorage > 0 && storage > maxStorage:
+			reason = imageGCReasonStorage
+		default:
+			return collections
+		}
+		var reclaimed int64
+		for _, chainID := range c.layers {
+			refs[chainID]--
+			if refs[chainID] == 0 {
+				reclaimed += sizes[chainID]
+			}
+		}
+		storage -= reclaimed
+		collections = append(collections, imageGCCollection{id: c.id, reason: reason, reclaimed: reclaimed})
+	}
+	return collections
+}
+
+type byLastUsed []imageGCCandidate
+
+func (s byLastUsed) Len() int           { return len(s) }
+func (s byLastUsed) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s byLastUsed) Less(i, j int) bool { return s[i].lastUsed.Before(s[j].lastUsed) }
+
+// imageGC runs the image garbage collector at the interval of the policy.
+func (daemon *Daemon) imageGC(policy *imageGCPolicy) {
+	for range time.Tick(policy.interval) {
+		if daemon.shutdown {
+			return
+		}
+		daemon.collectImages(policy, time.Now())
+	}
+}
+
+// collectImages deletes the images the policy collects, and logs a "gc"
+// image event with the space reclaimed for each of them.
+func (daemon *Daemon) collectImages(policy *imageGCPolicy, now time.Time) {
+	inUse := daemon.imagesInUse()
+
+	refs := make(map[layer.ChainID]int)
+	sizes := make(map[layer.ChainID]int64)
+	heads := daemon.imageStore.Heads()
+	var candidates []imageGCCandidate
+	for id, img := range daemon.imageStore.Map() {
+		_, head := heads[id]
+		if !head && !inUse[id] && len(daemon.referenceStore.References(id)) == 0 {
+			// Untagged parents are deleted with their last child, and their
+			// layers are those of their children.
+			continue
+		}
+
+		layers := imageLayers(img)
+		// The layers are referenced once per image using them, so that
+		// they are only reclaimed with the last one.
+		for _, chainID := range layers {
+			if _, ok := sizes[chainID]; !ok {
+				sizes[chainID] = daemon.layerDiffSize(chainID)
+			}
+			refs[chainID]++
+		}
+
+		if !head || inUse[id] || daemon.imageProtected(policy, id) {
+			continue
+		}
+		lastUsed, err := daemon.imageStore.GetLastUsed(id)
+		if err != nil {
+			// The image was never used, count from its first sighting.
+			lastUsed = now
+			if err := daemon.imageStore.SetLastUsed(id, now); err != nil {
+				logrus.Warnf("Image GC: failed to record the first sighting of image %s: %v", id, err)
+			}
+		}
+		candidates = append(candidates, imageGCCandidate{id: id, lastUsed: lastUsed, layers: layers})
+	}
+
+	var (
+		collected int
+		reclaimed int64
+	)
+	for _, c := range planImageGC(candidates, refs, sizes, policy.keep, policy.maxStorage, now) {
+		// A container may have been created from the image since the plan.
+		if daemon.imagesInUse()[c.id] {
+			continue
+		}
+		if _, err := daemon.ImageDelete(c.id.String(), true, true); err != nil {
+			logrus.Warnf("Image GC: failed to delete image %s: %v", c.id, err)
+			continue
+		}
+		collected++
+		reclaimed += c.reclaimed
+		daemon.LogImageEventWithAttributes(c.id.String(), "", "gc", map[string]string{
+			"reason":    c.reason,
+			"reclaimed": strconv.FormatInt(c.reclaimed, 10),
+		})
+	}
+	if collected > 0 {
+		logrus.Infof("Image GC: deleted %d images, reclaimed %s", collected, units.HumanSize(float64(reclaimed)))
+	}
+}
+
+// imagesInUse returns the IDs of the images of the containers.
+func (daemon *Daemon) imagesInUse() map[image.ID]bool {
+	inUse := make(map[image.ID]bool)
+	for _, c := range daemon.List() {
+		inUse[c.ImageID] = true
+	}
+	return inUse
+}
+
+// imageProtected returns true if a reference to the image is protected by
+// the policy.
+func (daemon *Daemon) imageProtected(policy *imageGCPolicy, id image.ID) bool {
+	for _, ref := range daemon.referenceStore.References(id) {
+		if policy.protects(ref) {
+			return true
+		}
+	}
+	return false
+}
+
+// layerDiffSize returns the size of the top layer of a chain, or 0 if it
+// is unknown.
+func (daemon *Daemon) layerDiffSize(chainID layer.ChainID) int64 {
+	l, err := daemon.layerStore.Get(chainID)
+	if err != nil {
+		return 0
+	}
+	defer layer.ReleaseAndLog(daemon.layerStore, l)
+	size, err := l.DiffSize()
+	if err != nil {
+		return 0
+	}
+	return size
+}
@@ -0,0 +1,95 @@
+package daemon
+
+import (
+	"testing"
+	"time"
+
+	"github.com/docker/docker/image"
+	"github.com/docker/docker/layer"
+	"github.com/docker/docker/reference"
+)
+
+func TestParseImageGCPolicy(t *testing.T) {
+	policy, err := parseImageGCPolicy(&Config{})
+	if err != nil || policy != nil {
+		t.Fatalf("expected the image GC to be disabled, got %+v, %v", policy, err)
+	}
+
+	config := &Config{}
+	config.ImageGCInterval = "1h"
+	config.ImageGCKeepDays = 7
+	config.ImageGCMaxStorage = "2GB"
+	config.ImageGCProtect = []string{"busybox", "ubuntu:14.04"}
+	policy, err = parseImageGCPolicy(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if policy.interval != time.Hour || policy.keep != 7*24*time.Hour || policy.maxStorage != 2<<30 || len(policy.protected) != 2 {
+		t.Fatalf("unexpected policy %+v", policy)
+	}
+
+	for _, ref := range []string{"busybox:latest", "busybox:1.24", "ubuntu:14.04"} {
+		named, _ := reference.ParseNamed(ref)
+		if !policy.protects(named) {
+			t.Fatalf("expected %s to be protected", ref)
+		}
+	}
+	for _, ref := range []string{"ubuntu:latest", "debian"} {
+		named, _ := reference.ParseNamed(ref)
+		if policy.protects(named) {
+			t.Fatalf("expected %s not to be protected", ref)
+		}
+	}
+
+	for _, c := range []*Config{
+		{CommonConfig: CommonConfig{ImageGCInterval: "hourly", ImageGCKeepDays: 1}},
+		{CommonConfig: CommonConfig{ImageGCInterval: "1h"}},
+		{CommonConfig: CommonConfig{ImageGCInterval: "1h", ImageGCKeepDays: -1}},
+		{CommonConfig: CommonConfig{ImageGCInterval: "1h", ImageGCMaxStorage: "lots"}},
+		{CommonConfig: CommonConfig{ImageGCInterval: "1h", ImageGCKeepDays: 1, ImageGCProtect: []string{"Busybox"}}},
+	} {
+		if _, err := parseImageGCPolicy(c); err == nil {
+			t.Fatalf("expected %s, %d, %q, %v to be rejected", c.ImageGCInterval, c.ImageGCKeepDays, c.ImageGCMaxStorage, c.ImageGCProtect)
+		}
+	}
+}
+
+func TestPlanImageGC(t *testing.T) {
+	now := time.Date(2016, 4, 1, 12, 0, 0, 0, time.UTC)
+	sizes := map[layer.ChainID]int64{"base": 100, "a": 10, "b": 20, "c": 30, "kept": 1000}
+	candidates := func() []imageGCCandidate {
+		return []imageGCCandidate{
+			{id: image.ID("c"), lastUsed: now.Add(-time.Hour), layers: []layer.ChainID{"base", "c"}},
+			{id: image.ID("a"), lastUsed: now.Add(-10 * 24 * time.Hour), layers: []layer.ChainID{"base", "a"}},
+			{id: image.ID("b"), lastUsed: now.Add(-2 * 24 * time.Hour), layers: []layer.ChainID{"base", "b"}},
+		}
+	}
+	refs := func() map[layer.ChainID]int {
+		return map[layer.ChainID]int{"base": 3, "a": 1, "b": 1, "c": 1, "kept": 1}
+	}
+
+	collections := planImageGC(candidates(), refs(), sizes, 7*24*time.Hour, 0, now)
+	if len(collections) != 1 || collections[0] != (imageGCCollection{id: image.ID("a"), reason: imageGCReasonAge, reclaimed: 10}) {
+		t.Fatalf("expected the unused image to be collected, got %+v", collections)
+	}
+
+	// 1160 bytes in use, the base layer is reclaimed with the last image.
+	collections = planImageGC(candidates(), refs(), sizes, 7*24*time.Hour, 1050, now)
+	expected := []imageGCCollection{
+		{id: image.ID("a"), reason: imageGCReasonAge, reclaimed: 10},
+		{id: image.ID("b"), reason: imageGCReasonStorage, reclaimed: 20},
+		{id: image.ID("c"), reason: imageGCReasonStorage, reclaimed: 130},
+	}
+	if len(collections) != len(expected) {
+		t.Fatalf("expected %+v, got %+v", expected, collections)
+	}
+	for i := range expected {
+		if collections[i] != expected[i] {
+			t.Fatalf("expected %+v, got %+v", expected, collections)
+		}
+	}
+
+	if collections := planImageGC(candidates(), refs(), sizes, 0, 2000, now); len(collections) != 0 {
+		t.Fatalf("expected nothing to be collected, got %+v", collections)
+	}
+}
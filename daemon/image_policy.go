@@ -0,0 +1,70 @@
+package daemon
+
+import (
+	"fmt"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/docker/docker/image"
+	"github.com/docker/docker/reference"
+)
+
+const (
+	// imageReferencePolicyAllow creates the containers of images
+	// referenced by tag without complaint.
+	imageReferencePolicyAllow = "allow"
+	// imageReferencePolicyWarn warns the client creating a container of an
+	// image referenced by tag.
+	imageReferencePolicyWarn = "warn"
+	// imageReferencePolicyReject refuses to create the containers of
+	// images referenced by tag.
+	imageReferencePolicyReject = "reject"
+)
+
+func validateImageReferencePolicy(policy string) error {
+	switch policy {
+	case "", imageReferencePolicyAllow, imageReferencePolicyWarn, imageReferencePolicyReject:
+		return nil
+	}
+	return fmt.Errorf("invalid image reference policy %q, expected %s, %s or %s", policy, imageReferencePolicyAllow, imageReferencePolicyWarn, imageReferencePolicyReject)
+}
+
+// checkImageReference applies the image reference policy to the image of a
+// new container, referenced by refOrID and resolved to imgID. It returns the
+// reference by digest of the image when it is known, along with the warnings
+// of the policy. Tags can be moved to other images, while IDs and digests
+// always designate the same image.
+func (daemon *Daemon) checkImageReference(refOrID string, imgID image.ID) (string, []string, error) {
+	_, ref, err := reference.ParseIDOrReference(refOrID)
+	if err != nil || ref == nil {
+		return "", nil, nil
+	}
+	if canonical, ok := ref.(reference.Canonical); ok {
+		return canonical.String(), nil, nil
+	}
+	tagged, ok := reference.WithDefaultTag(ref).(reference.NamedTagged)
+	if !ok {
+		return "", nil, nil
+	}
+	if id, err := daemon.referenceStore.Get(tagged); err != nil || id != imgID {
+		// The image was found by ID.
+		return "", nil, nil
+	}
+
+	if daemon.configStore.ResolveImageDigests {
+		for _, r := range daemon.referenceStore.References(imgID) {
+			if canonical, ok := r.(reference.Canonical); ok && canonical.Name() == tagged.Name() {
+				return canonical.String(), nil, nil
+			}
+		}
+	}
+
+	switch daemon.configStore.ImageReferencePolicy {
+	case imageReferencePolicyReject:
+		return "", nil, fmt.Errorf("image %s is referenced by the mutable tag %s, which the image reference policy rejects: reference the image by digest", refOrID, tagged.Tag())
+	case imageReferencePolicyWarn:
+		warning := fmt.Sprintf("Image %s is referenced by the mutable tag %s, reference it by digest to always run the same image", refOrID, tagged.Tag())
+		logrus.Warn(warning)
+		return "", []string{warning}, nil
+	}
+	return "", nil, nil
+}
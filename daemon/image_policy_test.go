@@ -0,0 +1,73 @@
+package daemon
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/docker/distribution/digest"
+	"github.com/docker/docker/image"
+	"github.com/docker/docker/reference"
+)
+
+func TestCheckImageReference(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "image-policy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+	store, err := reference.NewReferenceStore(filepath.Join(tmp, "repositories.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	imgID := image.ID(digest.FromBytes([]byte("image")))
+	tagged, _ := reference.ParseNamed("busybox:latest")
+	if err := store.AddTag(tagged, imgID, false); err != nil {
+		t.Fatal(err)
+	}
+	canonical, _ := reference.ParseNamed("busybox@" + digest.FromBytes([]byte("manifest")).String())
+	if err := store.AddDigest(canonical.(reference.Canonical), imgID, false); err != nil {
+		t.Fatal(err)
+	}
+
+	daemon := &Daemon{referenceStore: store, configStore: &Config{}}
+	daemon.configStore.ImageReferencePolicy = imageReferencePolicyReject
+
+	for _, refOrID := range []string{imgID.String(), imgID.String()[7:19], canonical.String()} {
+		if _, _, err := daemon.checkImageReference(refOrID, imgID); err != nil {
+			t.Fatalf("expected %s to be accepted: %v", refOrID, err)
+		}
+	}
+	if resolved, _, _ := daemon.checkImageReference(canonical.String(), imgID); resolved != canonical.String() {
+		t.Fatalf("expected the digest to be recorded, got %q", resolved)
+	}
+	if _, _, err := daemon.checkImageReference("busybox", imgID); err == nil {
+		t.Fatal("expected a tag to be rejected")
+	}
+
+	daemon.configStore.ImageReferencePolicy = imageReferencePolicyWarn
+	resolved, warnings, err := daemon.checkImageReference("busybox", imgID)
+	if err != nil || resolved != "" || len(warnings) != 1 {
+		t.Fatalf("expected a warning, got %q, %v, %v", resolved, warnings, err)
+	}
+
+	daemon.configStore.ImageReferencePolicy = imageReferencePolicyReject
+	daemon.configStore.ResolveImageDigests = true
+	resolved, warnings, err = daemon.checkImageReference("busybox:latest", imgID)
+	if err != nil || len(warnings) != 0 || resolved != canonical.String() {
+		t.Fatalf("expected the tag to be resolved to %s, got %q, %v, %v", canonical, resolved, warnings, err)
+	}
+}
+
+func TestValidateImageReferencePolicy(t *testing.T) {
+	for _, policy := range []string{"", "allow", "warn", "reject"} {
+		if err := validateImageReferencePolicy(policy); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := validateImageReferencePolicy("deny"); err == nil {
+		t.Fatal("expected an unknown policy to be rejected")
+	}
+}
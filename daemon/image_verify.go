@@ -0,0 +1,138 @@
+package daemon
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/docker/distribution/digest"
+	"github.com/docker/docker/image"
+	"github.com/docker/docker/layer"
+	"github.com/docker/docker/pkg/progress"
+	"github.com/docker/docker/pkg/streamformatter"
+	"github.com/docker/docker/pkg/stringid"
+	"github.com/docker/engine-api/types"
+)
+
+// VerifyImage hashes again the content of the layers of an image stored on
+// disk, and compares it with the digests of the layers in the image config,
+// to detect the layers corrupted by disk faults. With repull, an image with
+// corrupted layers is deleted and pulled again from its references.
+func (daemon *Daemon) VerifyImage(refOrID string, repull bool, metaHeaders map[string][]string, authConfig *types.AuthConfig, outStream io.Writer) error {
+	img, err := daemon.GetImage(refOrID)
+	if err != nil {
+		return err
+	}
+	sf := streamformatter.NewJSONStreamFormatter()
+	out := sf.NewProgressOutput(outStream, false)
+
+	corrupted := make(map[layer.ChainID]bool)
+	layers := imageLayers(img)
+	for i, chainID := range layers {
+		id := stringid.TruncateID(img.RootFS.DiffIDs[i].String())
+		if err := daemon.verifyLayer(chainID, img.RootFS.DiffIDs[i], id, out); err != nil {
+			progress.Updatef(out, id, "Corrupted: %v", err)
+			corrupted[chainID] = true
+			continue
+		}
+		progress.Update(out, id, "Verified")
+	}
+
+	if len(corrupted) == 0 {
+		progress.Messagef(out, "", "Status: verified the %d layers of %s", len(layers), refOrID)
+		return nil
+	}
+	if !repull {
+		return fmt.Errorf("%d of the %d layers of %s are corrupted, verify the image with --repull to pull them again", len(corrupted), len(layers), refOrID)
+	}
+	return daemon.repullImage(img.ID(), corrupted, metaHeaders, authConfig, outStream)
+}
+
+// verifyLayer hashes the tar stream of a layer, and compares the digest with
+// its diff ID.
+func (daemon *Daemon) verifyLayer(chainID layer.ChainID, diffID layer.DiffID, id string, out progress.Output) error {
+	l, err := daemon.layerStore.Get(chainID)
+	if err != nil {
+		return err
+	}
+	defer layer.ReleaseAndLog(daemon.layerStore, l)
+
+	ts, err := l.TarStream()
+	if err != nil {
+		return err
+	}
+	defer ts.Close()
+
+	verifier, err := digest.NewDigestVerifier(digest.Digest(diffID))
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(verifier, progress.NewProgressReader(ts, out, 0, id, "Verifying")); err != nil {
+		return err
+	}
+	if !verifier.Verified() {
+		return fmt.Errorf("content does not match digest %s", diffID)
+	}
+	return nil
+}
+
+// repullImage deletes an image with corrupted layers and pulls it again
+// from its references. The corrupted layers must be released by the
+// deletion, or the pull would reuse them, so they must not be shared with
+// other images, and no container may use the image.
+func (daemon *Daemon) repullImage(imgID image.ID, corrupted map[layer.ChainID]bool, metaHeaders map[string][]string, authConfig *types.AuthConfig, outStream io.Writer) error {
+	refs := daemon.referenceStore.References(imgID)
+	if len(refs) == 0 {
+		return fmt.Errorf("image %s has corrupted layers and no reference to pull it again from", stringid.TruncateID(imgID.String()))
+	}
+
+	// The untagged parents of the image are deleted with it.
+	deleted := map[image.ID]bool{imgID: true}
+	for id := imgID; ; {
+		parent, err := daemon.imageStore.GetParent(id)
+		if err != nil || len(daemon.referenceStore.References(parent)) > 0 || len(daemon.imageStore.Children(parent)) > 1 {
+			break
+		}
+		deleted[parent] = true
+		id = parent
+	}
+	inUse := daemon.imagesInUse()
+	for id := range deleted {
+		if inUse[id] {
+			return fmt.Errorf("image %s has corrupted layers and is used by containers, remove them to pull the image again", stringid.TruncateID(id.String()))
+		}
+	}
+	for id, img := range daemon.imageStore.Map() {
+		if deleted[id] {
+			continue
+		}
+		for _, chainID := range imageLayers(img) {
+			if corrupted[chainID] {
+				return fmt.Errorf("corrupted layer %s is shared with image %s, delete it to pull the image again", chainID, stringid.TruncateID(id.String()))
+			}
+		}
+	}
+
+	if _, err := daemon.ImageDelete(imgID.String(), true, true); err != nil {
+		return err
+	}
+	for _, ref := range refs {
+		if err := daemon.PullImage(ref, "", metaHeaders, authConfig, outStream); err != nil {
+			return fmt.Errorf("failed to pull %s again: %v", ref.String(), err)
+		}
+	}
+	return nil
+}
+
+// imageLayers returns the chain IDs of all the layers of an image.
+func imageLayers(img *image.Image) []layer.ChainID {
+	if img.RootFS == nil {
+		return nil
+	}
+	var layers []layer.ChainID
+	rootFS := *img.RootFS
+	for i := range img.RootFS.DiffIDs {
+		rootFS.DiffIDs = img.RootFS.DiffIDs[:i+1]
+		layers = append(layers, rootFS.ChainID())
+	}
+	return layers
+}
@@ -0,0 +1,67 @@
+package daemon
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/docker/distribution/digest"
+	"github.com/docker/docker/image"
+	"github.com/docker/docker/layer"
+	"github.com/docker/docker/pkg/progress"
+)
+
+type verifyTestLayer struct {
+	layer.Layer
+	content []byte
+}
+
+func (l *verifyTestLayer) TarStream() (io.ReadCloser, error) {
+	return ioutil.NopCloser(bytes.NewReader(l.content)), nil
+}
+
+type verifyTestLayerStore struct {
+	layer.Store
+	layers map[layer.ChainID]*verifyTestLayer
+}
+
+func (ls *verifyTestLayerStore) Get(chainID layer.ChainID) (layer.Layer, error) {
+	l, ok := ls.layers[chainID]
+	if !ok {
+		return nil, layer.ErrLayerDoesNotExist
+	}
+	return l, nil
+}
+
+func (ls *verifyTestLayerStore) Release(layer.Layer) ([]layer.Metadata, error) {
+	return nil, nil
+}
+
+func TestVerifyLayer(t *testing.T) {
+	diffIDs := []layer.DiffID{
+		layer.DiffID(digest.FromBytes([]byte("base"))),
+		layer.DiffID(digest.FromBytes([]byte("top"))),
+	}
+	img := &image.Image{RootFS: &image.RootFS{Type: "layers", DiffIDs: diffIDs}}
+	layers := imageLayers(img)
+	if len(layers) != 2 || layers[0] != layer.CreateChainID(diffIDs[:1]) || layers[1] != layer.CreateChainID(diffIDs) {
+		t.Fatalf("unexpected layers %v", layers)
+	}
+
+	daemon := &Daemon{layerStore: &verifyTestLayerStore{layers: map[layer.ChainID]*verifyTestLayer{
+		layers[0]: {content: []byte("base")},
+		layers[1]: {content: []byte("corrupted")},
+	}}}
+	out := progress.ChanOutput(make(chan progress.Progress, 100))
+
+	if err := daemon.verifyLayer(layers[0], diffIDs[0], "base", out); err != nil {
+		t.Fatalf("expected the base layer to be verified: %v", err)
+	}
+	if err := daemon.verifyLayer(layers[1], diffIDs[1], "top", out); err == nil {
+		t.Fatal("expected the top layer to be corrupted")
+	}
+	if err := daemon.verifyLayer(layer.ChainID(digest.FromBytes([]byte("missing"))), diffIDs[0], "missing", out); err == nil {
+		t.Fatal("expected a missing layer to be reported")
+	}
+}
@@ -138,8 +138,9 @@ func (daemon *Daemon) getInspectData(container *container.Container, size bool)
 		Path:         container.Path,
 		Args:         container.Args,
 		State:        containerState,
-		Image:        container.ImageID.String(),
-		LogPath:      container.LogPath,
+		Image:         container.ImageID.String(),
+		ResolvedImage: container.ResolvedImage,
+		LogPath:       container.LogPath,
 		Name:         container.Name,
 		RestartCount: container.RestartCount,
 		Driver:       container.Driver,
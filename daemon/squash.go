@@ -0,0 +1,171 @@
+package daemon
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"runtime"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/docker/docker/image"
+	"github.com/docker/docker/layer"
+	"github.com/docker/docker/pkg/archive"
+	"github.com/docker/docker/pkg/ioutils"
+	"github.com/docker/docker/pkg/stringid"
+)
+
+// SquashImage creates a new image with the layers of image id on top of
+// image parent collapsed into a single layer. The config of the image is
+// kept, and its history marks the collapsed history entries as empty
+// layers, followed by an entry for the new layer. Without a parent, all
+// the layers of the image are collapsed.
+func (daemon *Daemon) SquashImage(id, parent string) (string, error) {
+	if runtime.GOOS == "windows" {
+		return "", fmt.Errorf("Windows does not support squashing images")
+	}
+	img, err := daemon.imageStore.Get(image.ID(id))
+	if err != nil {
+		return "", err
+	}
+
+	parentRootFS := image.NewRootFS()
+	var parentHistory []image.History
+	if parent != "" {
+		parentImg, err := daemon.imageStore.Get(image.ID(parent))
+		if err != nil {
+			return "", err
+		}
+		parentRootFS = parentImg.RootFS
+		parentHistory = parentImg.History
+	}
+	if len(parentRootFS.DiffIDs) > len(img.RootFS.DiffIDs) {
+		return "", fmt.Errorf("image %s is not a parent of image %s", stringid.TruncateID(parent), stringid.TruncateID(id))
+	}
+	for i, diffID := range parentRootFS.DiffIDs {
+		if img.RootFS.DiffIDs[i] != diffID {
+			return "", fmt.Errorf("image %s is not a parent of image %s", stringid.TruncateID(parent), stringid.TruncateID(id))
+		}
+	}
+	if len(img.RootFS.DiffIDs)-len(parentRootFS.DiffIDs) < 2 {
+		// There is at most one layer to squash.
+		return id, nil
+	}
+
+	ts, err := daemon.squashedTarStream(img.RootFS.ChainID(), parentRootFS.ChainID())
+	if err != nil {
+		return "", err
+	}
+	defer ts.Close()
+
+	l, err := daemon.layerStore.Register(ts, parentRootFS.ChainID())
+	if err != nil {
+		return "", err
+	}
+	defer layer.ReleaseAndLog(daemon.layerStore, l)
+
+	newImage := *img
+	newImage.RootFS = &image.RootFS{}
+	*newImage.RootFS = *parentRootFS
+	newImage.RootFS.DiffIDs = append(append([]layer.DiffID(nil), parentRootFS.DiffIDs...), l.DiffID())
+	newImage.Parent = image.ID(parent)
+	newImage.History = squashHistory(img.History, len(parentHistory))
+	newImage.History = append(newImage.History, image.History{
+		Created:   time.Now().UTC(),
+		CreatedBy: fmt.Sprintf("squash %s", id),
+		Comment:   fmt.Sprintf("merge %s to %s", id, parent),
+	})
+
+	config, err := json.Marshal(&newImage)
+	if err != nil {
+		return "", err
+	}
+	newID, err := daemon.imageStore.Create(config)
+	if err != nil {
+		return "", err
+	}
+	if parent != "" {
+		if err := daemon.imageStore.SetParent(newID, image.ID(parent)); err != nil {
+			return "", err
+		}
+	}
+	return string(newID), nil
+}
+
+// squashHistory returns a copy of history, in which the entries following
+// the first parentEntries entries are marked as empty layers.
+func squashHistory(history []image.History, parentEntries int) []image.History {
+	squashed := make([]image.History, len(history))
+	copy(squashed, history)
+	if parentEntries > len(squashed) {
+		parentEntries = len(squashed)
+	}
+	for i := parentEntries; i < len(squashed); i++ {
+		squashed[i].EmptyLayer = true
+	}
+	return squashed
+}
+
+// squashedTarStream returns the changes of the filesystem of layer top from
+// the filesystem of layer parent, computed by comparing their mounts.
+func (daemon *Daemon) squashedTarStream(top, parent layer.ChainID) (io.ReadCloser, error) {
+	newDir, releaseNew, err := daemon.mountLayer(top)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		oldDir     string
+		releaseOld = func() {}
+	)
+	if parent != "" {
+		if oldDir, releaseOld, err = daemon.mountLayer(parent); err != nil {
+			releaseNew()
+			return nil, err
+		}
+	}
+	release := func() error {
+		releaseOld()
+		releaseNew()
+		return nil
+	}
+
+	changes, err := archive.ChangesDirs(newDir, oldDir)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	ts, err := archive.ExportChanges(newDir, changes, daemon.uidMaps, daemon.gidMaps)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	return ioutils.NewReadCloserWrapper(ts, func() error {
+		ts.Close()
+		return release()
+	}), nil
+}
+
+// mountLayer mounts the filesystem of a read-only layer through a
+// temporary read-write layer, and returns a function to release it.
+func (daemon *Daemon) mountLayer(chainID layer.ChainID) (string, func(), error) {
+	rwLayer, err := daemon.layerStore.CreateRWLayer(stringid.GenerateRandomID(), chainID, "", nil)
+	if err != nil {
+		return "", nil, err
+	}
+	release := func() {
+		if _, err := daemon.layerStore.ReleaseRWLayer(rwLayer); err != nil {
+			logrus.Errorf("Failed to release the layer mounted to squash an image: %v", err)
+		}
+	}
+	dir, err := rwLayer.Mount("")
+	if err != nil {
+		release()
+		return "", nil, err
+	}
+	return dir, func() {
+		if err := rwLayer.Unmount(); err != nil {
+			logrus.Errorf("Failed to unmount the layer mounted to squash an image: %v", err)
+		}
+		release()
+	}, nil
+}
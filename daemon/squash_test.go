@@ -0,0 +1,153 @@
+package daemon
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/docker/docker/daemon/graphdriver"
+	"github.com/docker/docker/daemon/graphdriver/vfs"
+	"github.com/docker/docker/image"
+	"github.com/docker/docker/layer"
+	"github.com/docker/docker/pkg/archive"
+	"github.com/docker/docker/pkg/idtools"
+)
+
+func squashTestTar(t *testing.T, files ...string) io.Reader {
+	buf := &bytes.Buffer{}
+	tw := tar.NewWriter(buf)
+	for _, name := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(name)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf
+}
+
+func TestSquashImage(t *testing.T) {
+	defer func(apply func(string, archive.Reader, *archive.TarOptions) (int64, error), copyWithTar func(string, string) error) {
+		graphdriver.ApplyUncompressedLayer = apply
+		vfs.CopyWithTar = copyWithTar
+	}(graphdriver.ApplyUncompressedLayer, vfs.CopyWithTar)
+	graphdriver.ApplyUncompressedLayer = archive.UnpackLayer
+	vfs.CopyWithTar = archive.CopyWithTar
+
+	tmp, err := ioutil.TempDir("", "squash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+
+	idMaps := []idtools.IDMap{{ContainerID: 0, HostID: os.Getuid(), Size: 1}}
+	gidMaps := []idtools.IDMap{{ContainerID: 0, HostID: os.Getgid(), Size: 1}}
+	driver, err := graphdriver.GetDriver("vfs", filepath.Join(tmp, "graph"), nil, idMaps, gidMaps)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fms, err := layer.NewFSMetadataStore(filepath.Join(tmp, "layers"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ls, err := layer.NewStoreFromGraphDriver(fms, driver)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ifs, err := image.NewFSStoreBackend(filepath.Join(tmp, "images"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	is, err := image.NewImageStore(ifs, ls)
+	if err != nil {
+		t.Fatal(err)
+	}
+	daemon := &Daemon{layerStore: ls, imageStore: is}
+
+	// The base image has files a and c, the build adds b then removes a.
+	var (
+		rootFS  = image.NewRootFS()
+		history []image.History
+		ids     []image.ID
+	)
+	for _, layerTar := range []io.Reader{
+		squashTestTar(t, "a", "c"),
+		squashTestTar(t, "b"),
+		squashTestTar(t, ".wh.a"),
+	} {
+		l, err := ls.Register(layerTar, rootFS.ChainID())
+		if err != nil {
+			t.Fatal(err)
+		}
+		rootFS.Append(l.DiffID())
+		history = append(history, image.History{CreatedBy: string(l.DiffID())})
+		config, err := json.Marshal(&image.Image{RootFS: rootFS, History: history})
+		if err != nil {
+			t.Fatal(err)
+		}
+		id, err := is.Create(config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+
+	squashedID, err := daemon.SquashImage(ids[2].String(), ids[0].String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	squashed, err := is.Get(image.ID(squashedID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(squashed.RootFS.DiffIDs) != 2 || squashed.RootFS.DiffIDs[0] != rootFS.DiffIDs[0] {
+		t.Fatalf("expected the build to be squashed on top of the base layer, got %v", squashed.RootFS.DiffIDs)
+	}
+	if parent, err := is.GetParent(image.ID(squashedID)); err != nil || parent != ids[0] {
+		t.Fatalf("expected the parent of the squashed image to be %s, got %s, %v", ids[0], parent, err)
+	}
+	if len(squashed.History) != 4 || squashed.History[0].EmptyLayer || !squashed.History[1].EmptyLayer || !squashed.History[2].EmptyLayer || squashed.History[3].EmptyLayer {
+		t.Fatalf("unexpected history %+v", squashed.History)
+	}
+
+	l, err := ls.Get(squashed.RootFS.ChainID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer layer.ReleaseAndLog(ls, l)
+	ts, err := l.TarStream()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ts.Close()
+	var names []string
+	tr := tar.NewReader(ts)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, hdr.Name)
+	}
+	sort.Strings(names)
+	if len(names) != 2 || names[0] != ".wh.a" || names[1] != "b" {
+		t.Fatalf("expected the squashed layer to add b and remove a, got %v", names)
+	}
+
+	if id, err := daemon.SquashImage(ids[1].String(), ids[0].String()); err != nil || id != ids[1].String() {
+		t.Fatalf("expected a single layer not to be squashed, got %s, %v", id, err)
+	}
+}
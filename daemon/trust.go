@@ -0,0 +1,120 @@
+package daemon
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"path/filepath"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/docker/docker/distribution/trust"
+	"github.com/docker/docker/image"
+	"github.com/docker/docker/reference"
+	"github.com/docker/docker/registry"
+	"github.com/docker/engine-api/types"
+)
+
+func newTrustVerifier(config *Config, registryService *registry.Service) (*trust.Verifier, error) {
+	if config.ContentTrustServer != "" {
+		u, err := url.Parse(config.ContentTrustServer)
+		if err != nil || u.Scheme != "https" {
+			return nil, fmt.Errorf("valid https URL required for content-trust-server, got %s", config.ContentTrustServer)
+		}
+	}
+	pins, err := parseContentTrustPins(config.ContentTrustPins)
+	if err != nil {
+		return nil, err
+	}
+	return trust.NewVerifier(trust.Config{
+		Root:            filepath.Join(config.Root, "trust"),
+		Server:          config.ContentTrustServer,
+		Pins:            pins,
+		RegistryService: registryService,
+	}), nil
+}
+
+// pullTrustedImage pulls the signed digests of the tags of ref, and tags
+// them. A digest is only pulled if it is signed for one of the tags of its
+// repository.
+func (daemon *Daemon) pullTrustedImage(ref reference.Named, platform string, metaHeaders map[string][]string, authConfig *types.AuthConfig, outStream io.Writer) error {
+	if canonical, ok := ref.(reference.Canonical); ok {
+		signed, err := daemon.trustVerifier.IsSigned(ref, canonical.Digest(), authConfig)
+		if err != nil {
+			return err
+		}
+		if !signed {
+			return fmt.Errorf("%s is not signed", ref.String())
+		}
+		return daemon.pullImage(ref, platform, metaHeaders, authConfig, outStream)
+	}
+
+	var targets []trust.Target
+	if tagged, ok := ref.(reference.NamedTagged); ok {
+		dgst, err := daemon.trustVerifier.Resolve(tagged, authConfig)
+		if err != nil {
+			return err
+		}
+		targets = append(targets, trust.Target{Tag: tagged.Tag(), Digest: dgst})
+	} else {
+		var err error
+		if targets, err = daemon.trustVerifier.Targets(ref, authConfig); err != nil {
+			return err
+		}
+	}
+
+	name, err := reference.WithName(ref.Name())
+	if err != nil {
+		return err
+	}
+	for _, t := range targets {
+		trustedRef, err := reference.WithDigest(name, t.Digest)
+		if err != nil {
+			return err
+		}
+		if err := daemon.pullImage(trustedRef, platform, metaHeaders, authConfig, outStream); err != nil {
+			return err
+		}
+		id, err := daemon.referenceStore.Get(trustedRef)
+		if err != nil {
+			return err
+		}
+		tagged, err := reference.WithTag(name, t.Tag)
+		if err != nil {
+			return err
+		}
+		if err := daemon.referenceStore.AddTag(tagged, id, true); err != nil {
+			return err
+		}
+		daemon.LogImageEvent(id.String(), tagged.String(), "tag")
+	}
+	return nil
+}
+
+// verifyTrustedImage checks that an image, or one of the images it was built
+// from, was pulled by a digest signed in the trust data of its repository.
+func (daemon *Daemon) verifyTrustedImage(id image.ID) error {
+	if daemon.trustVerifier == nil {
+		return nil
+	}
+	for parent := id; parent != ""; {
+		for _, ref := range daemon.referenceStore.References(parent) {
+			canonical, ok := ref.(reference.Canonical)
+			if !ok {
+				continue
+			}
+			signed, err := daemon.trustVerifier.IsSigned(canonical, canonical.Digest(), nil)
+			if err != nil {
+				logrus.Debugf("Could not verify %s: %v", canonical.String(), err)
+				continue
+			}
+			if signed {
+				return nil
+			}
+		}
+		var err error
+		if parent, err = daemon.imageStore.GetParent(parent); err != nil {
+			break
+		}
+	}
+	return fmt.Errorf("image %s is not signed, and content trust is enabled", id)
+}
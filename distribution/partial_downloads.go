@@ -0,0 +1,77 @@
+package distribution
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/docker/distribution/digest"
+)
+
+// partialDownloadPath returns the path of the partial download of a blob.
+func partialDownloadPath(dir string, dgst digest.Digest) string {
+	return filepath.Join(dir, string(dgst.Algorithm()), dgst.Hex())
+}
+
+// openPartialDownload opens the partial download of a blob, creating it if
+// the blob was never downloaded, and returns a verifier of the data it
+// already holds. The file is left at its end.
+func openPartialDownload(dir string, dgst digest.Digest) (*os.File, digest.Verifier, error) {
+	path := partialDownloadPath(dir, dgst)
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, nil, err
+	}
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, nil, err
+	}
+	// Mark the download as used, so that it is not pruned.
+	now := time.Now()
+	os.Chtimes(path, now, now)
+
+	verifier, err := digest.NewDigestVerifier(dgst)
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	if _, err := io.Copy(verifier, f); err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	return f, verifier, nil
+}
+
+// PrunePartialDownloads removes the partial layer downloads of dir which
+// were not resumed for maxAge.
+func PrunePartialDownloads(dir string, maxAge time.Duration) error {
+	algorithms, err := ioutil.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	for _, algorithm := range algorithms {
+		if !algorithm.IsDir() {
+			continue
+		}
+		downloads, err := ioutil.ReadDir(filepath.Join(dir, algorithm.Name()))
+		if err != nil {
+			return err
+		}
+		for _, download := range downloads {
+			if time.Since(download.ModTime()) < maxAge {
+				continue
+			}
+			path := filepath.Join(dir, algorithm.Name(), download.Name())
+			logrus.Debugf("Removing stale partial download %s", path)
+			if err := os.Remove(path); err != nil {
+				logrus.Warnf("Failed to remove stale partial download %s: %v", path, err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package distribution
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/docker/distribution/digest"
+	"github.com/docker/docker/pkg/progress"
+	"golang.org/x/net/context"
+)
+
+func TestOpenPartialDownload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "partial-downloads")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	data := []byte("layer data")
+	dgst := digest.FromBytes(data)
+
+	f, verifier, err := openPartialDownload(dir, dgst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Write(data[:4]); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	if verifier.Verified() {
+		t.Fatal("expected an empty download not to be verified")
+	}
+
+	// Resume the download as after a restart of the daemon.
+	f, verifier, err = openPartialDownload(dir, dgst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	offset, err := f.Seek(0, os.SEEK_CUR)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if offset != 4 {
+		t.Fatalf("expected the download to resume at 4, got %d", offset)
+	}
+	if _, err := f.Write(data[4:]); err != nil {
+		t.Fatal(err)
+	}
+	verifier.Write(data[4:])
+	if !verifier.Verified() {
+		t.Fatal("expected the resumed download to verify")
+	}
+}
+
+func TestDownloadCompletedPartialDownload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "partial-downloads")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	data := []byte("layer data")
+	dgst := digest.FromBytes(data)
+	path := partialDownloadPath(dir, dgst)
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// The repository is not set, so the layer must not be downloaded again.
+	ld := &v2LayerDescriptor{digest: dgst, downloadsDir: dir}
+	rc, size, err := ld.Download(context.Background(), progress.ChanOutput(make(chan progress.Progress, 10)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size != int64(len(data)) {
+		t.Fatalf("expected size %d, got %d", len(data), size)
+	}
+	read, err := ioutil.ReadAll(rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(read) != string(data) {
+		t.Fatalf("expected %q, got %q", data, read)
+	}
+
+	ld.Close()
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("expected the completed download to be removed, got %v", err)
+	}
+}
+
+func TestPrunePartialDownloads(t *testing.T) {
+	dir, err := ioutil.TempDir("", "partial-downloads")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := PrunePartialDownloads(filepath.Join(dir, "missing"), time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	stale := partialDownloadPath(dir, digest.FromBytes([]byte("stale")))
+	recent := partialDownloadPath(dir, digest.FromBytes([]byte("recent")))
+	if err := os.MkdirAll(filepath.Dir(stale), 0700); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{stale, recent} {
+		if err := ioutil.WriteFile(path, []byte("data"), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	old := time.Now().Add(-2 * time.Hour)
+	if err := os.Chtimes(stale, old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := PrunePartialDownloads(dir, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(stale); !os.IsNotExist(err) {
+		t.Fatalf("expected the stale download to be removed, got %v", err)
+	}
+	if _, err := os.Stat(recent); err != nil {
+		t.Fatalf("expected the recent download to be kept, got %v", err)
+	}
+}
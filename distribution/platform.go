@@ -0,0 +1,151 @@
+package distribution
+
+import (
+	"encoding/json"
+	"fmt"
+	"runtime"
+	"strings"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/digest"
+	"github.com/docker/distribution/manifest/manifestlist"
+	"github.com/docker/distribution/manifest/schema2"
+)
+
+const (
+	// mediaTypeOCIImageIndex is the media type of OCI image indexes, which
+	// are read like manifest lists.
+	mediaTypeOCIImageIndex = "application/vnd.oci.image.index.v1+json"
+	// mediaTypeOCIManifest is the media type of OCI image manifests, which
+	// are read like schema2 manifests.
+	mediaTypeOCIManifest = "application/vnd.oci.image.manifest.v1+json"
+)
+
+func init() {
+	indexFunc := func(b []byte) (distribution.Manifest, distribution.Descriptor, error) {
+		m := new(manifestlist.DeserializedManifestList)
+		if err := m.UnmarshalJSON(b); err != nil {
+			return nil, distribution.Descriptor{}, err
+		}
+		return m, distribution.Descriptor{Digest: digest.FromBytes(b), Size: int64(len(b)), MediaType: mediaTypeOCIImageIndex}, nil
+	}
+	if err := distribution.RegisterManifestSchema(mediaTypeOCIImageIndex, indexFunc); err != nil {
+		panic(fmt.Sprintf("Unable to register manifest: %s", err))
+	}
+
+	manifestFunc := func(b []byte) (distribution.Manifest, distribution.Descriptor, error) {
+		m := new(schema2.DeserializedManifest)
+		if err := m.UnmarshalJSON(b); err != nil {
+			return nil, distribution.Descriptor{}, err
+		}
+		return m, distribution.Descriptor{Digest: digest.FromBytes(b), Size: int64(len(b)), MediaType: mediaTypeOCIManifest}, nil
+	}
+	if err := distribution.RegisterManifestSchema(mediaTypeOCIManifest, manifestFunc); err != nil {
+		panic(fmt.Sprintf("Unable to register manifest: %s", err))
+	}
+}
+
+// Platform is the platform of the image pulled from a manifest list or an
+// OCI image index.
+type Platform struct {
+	OS           string
+	Architecture string
+	// Variant is the optional variant of the CPU, like v7 for arm.
+	Variant string
+}
+
+// invalidPlatformError is returned for platforms that are not written as
+// os/arch[/variant].
+type invalidPlatformError string
+
+func (e invalidPlatformError) Error() string {
+	return fmt.Sprintf("invalid platform %q, expected os/arch[/variant]", string(e))
+}
+
+// IsValidationError makes the API answer with a 400 status.
+func (e invalidPlatformError) IsValidationError() bool {
+	return true
+}
+
+// ParsePlatform parses a platform written as os/arch[/variant]. An empty
+// string is the zero Platform, which selects the platform of the daemon.
+func ParsePlatform(s string) (Platform, error) {
+	if s == "" {
+		return Platform{}, nil
+	}
+	parts := strings.Split(strings.ToLower(s), "/")
+	if len(parts) < 2 || len(parts) > 3 || parts[0] == "" || parts[1] == "" {
+		return Platform{}, invalidPlatformError(s)
+	}
+	p := Platform{OS: parts[0], Architecture: parts[1]}
+	if len(parts) == 3 {
+		if parts[2] == "" {
+			return Platform{}, invalidPlatformError(s)
+		}
+		p.Variant = parts[2]
+	}
+	return p, nil
+}
+
+// orDefault returns the platform of the daemon if p is the zero Platform.
+func (p Platform) orDefault() Platform {
+	if p.OS == "" {
+		return Platform{OS: runtime.GOOS, Architecture: runtime.GOARCH}
+	}
+	return p
+}
+
+// String returns the platform as os/arch[/variant].
+func (p Platform) String() string {
+	s := p.OS + "/" + p.Architecture
+	if p.Variant != "" {
+		s += "/" + p.Variant
+	}
+	return s
+}
+
+// matches returns whether the platform of a manifest list entry suits p. An
+// empty variant matches any variant.
+func (p Platform) matches(spec manifestlist.PlatformSpec) bool {
+	return spec.OS == p.OS && spec.Architecture == p.Architecture && (p.Variant == "" || spec.Variant == p.Variant)
+}
+
+// selectManifest returns the digest of the manifest of a platform in a
+// manifest list.
+func selectManifest(mfstList *manifestlist.DeserializedManifestList, platform Platform) (digest.Digest, error) {
+	var available []string
+	for _, manifestDescriptor := range mfstList.Manifests {
+		if platform.matches(manifestDescriptor.Platform) {
+			return manifestDescriptor.Digest, nil
+		}
+		p := Platform{OS: manifestDescriptor.Platform.OS, Architecture: manifestDescriptor.Platform.Architecture, Variant: manifestDescriptor.Platform.Variant}
+		available = append(available, p.String())
+	}
+	return "", fmt.Errorf("no matching manifest for %s in the manifest list entries, available platforms: %s", platform, strings.Join(available, ", "))
+}
+
+// checkImagePlatform returns an error if the configuration of an image that
+// is not part of a manifest list is for another platform than the one
+// requested. Images that do not tell their platform are accepted.
+func checkImagePlatform(configJSON []byte, platform Platform) error {
+	var config struct {
+		OS           string `json:"os"`
+		Architecture string `json:"architecture"`
+		Variant      string `json:"variant"`
+	}
+	if err := json.Unmarshal(configJSON, &config); err != nil {
+		return err
+	}
+	if config.OS == "" || config.Architecture == "" {
+		return nil
+	}
+	spec := manifestlist.PlatformSpec{OS: config.OS, Architecture: config.Architecture, Variant: config.Variant}
+	if config.Variant == "" {
+		spec.Variant = platform.Variant
+	}
+	if !platform.matches(spec) {
+		actual := Platform{OS: config.OS, Architecture: config.Architecture, Variant: config.Variant}
+		return fmt.Errorf("image is for platform %s, not the requested %s", actual, platform)
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package distribution
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/digest"
+	"github.com/docker/distribution/manifest/manifestlist"
+	"github.com/docker/distribution/manifest/schema2"
+)
+
+func TestParsePlatform(t *testing.T) {
+	valid := map[string]Platform{
+		"":             {},
+		"linux/amd64":  {OS: "linux", Architecture: "amd64"},
+		"Linux/ARM/v7": {OS: "linux", Architecture: "arm", Variant: "v7"},
+	}
+	for s, expected := range valid {
+		p, err := ParsePlatform(s)
+		if err != nil {
+			t.Fatalf("%q: unexpected error %v", s, err)
+		}
+		if p != expected {
+			t.Fatalf("%q: expected %+v, got %+v", s, expected, p)
+		}
+	}
+	for _, s := range []string{"linux", "linux/", "/amd64", "linux/arm/", "linux/arm/v7/extra"} {
+		if _, err := ParsePlatform(s); err == nil {
+			t.Fatalf("%q: expected an error", s)
+		}
+	}
+}
+
+func TestSelectManifest(t *testing.T) {
+	list := &manifestlist.DeserializedManifestList{ManifestList: manifestlist.ManifestList{
+		Manifests: []manifestlist.ManifestDescriptor{
+			{Descriptor: distribution.Descriptor{Digest: "sha256:amd64"}, Platform: manifestlist.PlatformSpec{OS: "linux", Architecture: "amd64"}},
+			{Descriptor: distribution.Descriptor{Digest: "sha256:armv6"}, Platform: manifestlist.PlatformSpec{OS: "linux", Architecture: "arm", Variant: "v6"}},
+			{Descriptor: distribution.Descriptor{Digest: "sha256:armv7"}, Platform: manifestlist.PlatformSpec{OS: "linux", Architecture: "arm", Variant: "v7"}},
+		},
+	}}
+
+	for platform, expected := range map[string]digest.Digest{
+		"linux/amd64":  "sha256:amd64",
+		"linux/arm":    "sha256:armv6",
+		"linux/arm/v7": "sha256:armv7",
+	} {
+		p, _ := ParsePlatform(platform)
+		dgst, err := selectManifest(list, p)
+		if err != nil {
+			t.Fatalf("%s: unexpected error %v", platform, err)
+		}
+		if dgst != expected {
+			t.Fatalf("%s: expected %s, got %s", platform, expected, dgst)
+		}
+	}
+
+	_, err := selectManifest(list, Platform{OS: "windows", Architecture: "amd64"})
+	if err == nil || !strings.Contains(err.Error(), "linux/amd64, linux/arm/v6, linux/arm/v7") {
+		t.Fatalf("expected an error listing the available platforms, got %v", err)
+	}
+
+	if p := (Platform{}).orDefault(); p.OS != runtime.GOOS || p.Architecture != runtime.GOARCH {
+		t.Fatalf("expected the platform of the daemon, got %s", p)
+	}
+}
+
+func TestCheckImagePlatform(t *testing.T) {
+	config := []byte(`{"os":"linux","architecture":"arm","variant":"v7"}`)
+	if err := checkImagePlatform(config, Platform{OS: "linux", Architecture: "arm"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkImagePlatform(config, Platform{OS: "linux", Architecture: "amd64"}); err == nil {
+		t.Fatal("expected an error for another architecture")
+	}
+	if err := checkImagePlatform([]byte(`{}`), Platform{OS: "linux", Architecture: "amd64"}); err != nil {
+		t.Fatalf("images without a platform should be accepted, got %v", err)
+	}
+}
+
+func TestOCIManifestSchemas(t *testing.T) {
+	index := []byte(`{"schemaVersion":2,"manifests":[{"mediaType":"application/vnd.oci.image.manifest.v1+json","digest":"sha256:abc","size":1,"platform":{"os":"linux","architecture":"amd64"}}]}`)
+	m, _, err := distribution.UnmarshalManifest(mediaTypeOCIImageIndex, index)
+	if err != nil {
+		t.Fatal(err)
+	}
+	list, ok := m.(*manifestlist.DeserializedManifestList)
+	if !ok || len(list.Manifests) != 1 || list.Manifests[0].Platform.OS != "linux" {
+		t.Fatalf("unexpected manifest %#v", m)
+	}
+
+	manifest := []byte(`{"schemaVersion":2,"config":{"digest":"sha256:def","size":1},"layers":[{"digest":"sha256:123","size":1}]}`)
+	m, _, err = distribution.UnmarshalManifest(mediaTypeOCIManifest, manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mfst, ok := m.(*schema2.DeserializedManifest); !ok || len(mfst.References()) != 1 {
+		t.Fatalf("unexpected manifest %#v", m)
+	}
+}
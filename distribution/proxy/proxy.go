@@ -0,0 +1,324 @@
+// Package proxy implements a pull-through cache of Docker Hub, which serves
+// the registry v2 API to the daemons of a network and keeps the manifests and
+// layers it proxies on disk.
+package proxy
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/docker/distribution/digest"
+	"github.com/docker/distribution/registry/api/errcode"
+	"github.com/docker/distribution/registry/api/v2"
+	"github.com/docker/docker/reference"
+	"github.com/docker/docker/registry"
+	"golang.org/x/net/context"
+)
+
+// Cache is a pull-through cache of Docker Hub.
+type Cache struct {
+	store    *store
+	upstream upstream
+}
+
+// New creates a cache keeping its data in root, which pulls from Docker Hub
+// through the registry service of the daemon.
+func New(root string, registryService *registry.Service) (*Cache, error) {
+	return newCache(root, &registryUpstream{service: registryService})
+}
+
+func newCache(root string, upstream upstream) (*Cache, error) {
+	s, err := newStore(root)
+	if err != nil {
+		return nil, err
+	}
+	return &Cache{store: s, upstream: upstream}, nil
+}
+
+// Serve serves the cache on addr until stop is called.
+func (c *Cache) Serve(addr string) (stop func(), err error) {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	go func() {
+		if err := http.Serve(l, c); err != nil && !strings.Contains(err.Error(), "use of closed network connection") {
+			logrus.Errorf("Registry cache stopped serving: %v", err)
+		}
+	}()
+	return func() { l.Close() }, nil
+}
+
+// ServeHTTP serves the pull endpoints of the registry v2 API.
+func (c *Cache) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Docker-Distribution-API-Version", "registry/2.0")
+	if r.Method != "GET" && r.Method != "HEAD" {
+		errcode.ServeJSON(w, errcode.ErrorCodeUnsupported)
+		return
+	}
+	if r.URL.Path == "/v2/" || r.URL.Path == "/v2" {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.Write([]byte("{}"))
+		return
+	}
+	if !strings.HasPrefix(r.URL.Path, "/v2/") {
+		http.NotFound(w, r)
+		return
+	}
+	path := strings.TrimPrefix(r.URL.Path, "/v2/")
+
+	if i := strings.LastIndex(path, "/manifests/"); i > 0 {
+		name, err := parseName(path[:i])
+		if err != nil {
+			errcode.ServeJSON(w, err)
+			return
+		}
+		c.serveManifest(w, r, name, path[i+len("/manifests/"):])
+		return
+	}
+	if i := strings.LastIndex(path, "/blobs/"); i > 0 {
+		name, err := parseName(path[:i])
+		if err != nil {
+			errcode.ServeJSON(w, err)
+			return
+		}
+		dgst, err := digest.ParseDigest(path[i+len("/blobs/"):])
+		if err != nil {
+			errcode.ServeJSON(w, v2.ErrorCodeDigestInvalid.WithDetail(err.Error()))
+			return
+		}
+		c.serveBlob(w, r, name, dgst)
+		return
+	}
+	http.NotFound(w, r)
+}
+
+// parseName parses the name of a Docker Hub repository.
+func parseName(s string) (reference.Named, error) {
+	name, err := reference.WithName(s)
+	if err != nil {
+		return nil, v2.ErrorCodeNameInvalid.WithDetail(err.Error())
+	}
+	if name.Hostname() != reference.DefaultHostname {
+		return nil, v2.ErrorCodeNameUnknown.WithDetail(fmt.Sprintf("only %s repositories are cached", reference.DefaultHostname))
+	}
+	return name, nil
+}
+
+func (c *Cache) serveManifest(w http.ResponseWriter, r *http.Request, name reference.Named, tagOrDigest string) {
+	var (
+		mediaType string
+		payload   []byte
+		dgst      digest.Digest
+		err       error
+	)
+	if dgst, err = digest.ParseDigest(tagOrDigest); err == nil {
+		mediaType, payload, err = c.manifestByDigest(name, dgst)
+	} else if _, err = reference.WithTag(name, tagOrDigest); err != nil {
+		errcode.ServeJSON(w, v2.ErrorCodeTagInvalid.WithDetail(err.Error()))
+		return
+	} else {
+		mediaType, payload, dgst, err = c.manifestByTag(name, tagOrDigest)
+	}
+	if err != nil {
+		logrus.Debugf("Registry cache failed to serve manifest %s of %s: %v", tagOrDigest, name, err)
+		errcode.ServeJSON(w, v2.ErrorCodeManifestUnknown.WithDetail(err.Error()))
+		return
+	}
+
+	w.Header().Set("Content-Type", mediaType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(payload)))
+	w.Header().Set("Docker-Content-Digest", dgst.String())
+	w.Header().Set("Etag", fmt.Sprintf(`"%s"`, dgst))
+	if r.Method == "GET" {
+		w.Write(payload)
+	}
+}
+
+// manifestByDigest returns a manifest from the cache, fetching it on a miss.
+func (c *Cache) manifestByDigest(name reference.Named, dgst digest.Digest) (string, []byte, error) {
+	if mediaType, payload, err := c.store.manifest(dgst); err == nil {
+		return mediaType, payload, nil
+	}
+	desc, payload, err := c.upstream.Manifest(context.Background(), name, dgst.String())
+	if err != nil {
+		return "", nil, err
+	}
+	if desc.Digest != dgst {
+		return "", nil, fmt.Errorf("manifest digest %s does not match %s", desc.Digest, dgst)
+	}
+	if err := c.store.putManifest(dgst, desc.MediaType, payload); err != nil {
+		logrus.Warnf("Registry cache failed to store manifest %s: %v", dgst, err)
+	}
+	return desc.MediaType, payload, nil
+}
+
+// manifestByTag fetches the manifest a tag points to, so that the tags
+// pushed since it was cached are pulled, and falls back to the manifest it
+// last pointed to when Docker Hub cannot be reached.
+func (c *Cache) manifestByTag(name reference.Named, tag string) (string, []byte, digest.Digest, error) {
+	desc, payload, err := c.upstream.Manifest(context.Background(), name, tag)
+	if err != nil {
+		dgst, tagErr := c.store.tag(name.RemoteName(), tag)
+		if tagErr != nil {
+			return "", nil, "", err
+		}
+		mediaType, payload, cacheErr := c.store.manifest(dgst)
+		if cacheErr != nil {
+			return "", nil, "", err
+		}
+		logrus.Warnf("Registry cache serving cached manifest of %s:%s: %v", name, tag, err)
+		return mediaType, payload, dgst, nil
+	}
+
+	if err := c.store.putManifest(desc.Digest, desc.MediaType, payload); err != nil {
+		logrus.Warnf("Registry cache failed to store manifest %s: %v", desc.Digest, err)
+	} else if err := c.store.setTag(name.RemoteName(), tag, desc.Digest); err != nil {
+		logrus.Warnf("Registry cache failed to store tag %s:%s: %v", name, tag, err)
+	}
+	return desc.MediaType, payload, desc.Digest, nil
+}
+
+func (c *Cache) serveBlob(w http.ResponseWriter, r *http.Request, name reference.Named, dgst digest.Digest) {
+	if f, err := c.store.openBlob(dgst); err == nil {
+		defer f.Close()
+		serveBlobContent(w, r, dgst, f)
+		return
+	}
+
+	if r.Method == "HEAD" {
+		desc, err := c.upstream.StatBlob(context.Background(), name, dgst)
+		if err != nil {
+			errcode.ServeJSON(w, v2.ErrorCodeBlobUnknown.WithDetail(err.Error()))
+			return
+		}
+		w.Header().Set("Content-Length", strconv.FormatInt(desc.Size, 10))
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Docker-Content-Digest", dgst.String())
+		return
+	}
+
+	// A range cannot be served while the blob is streamed, so fetch the
+	// whole blob first.
+	if r.Header.Get("Range") != "" {
+		if err := c.fetchBlob(name, dgst, nil); err != nil {
+			errcode.ServeJSON(w, v2.ErrorCodeBlobUnknown.WithDetail(err.Error()))
+			return
+		}
+		f, err := c.store.openBlob(dgst)
+		if err != nil {
+			errcode.ServeJSON(w, errcode.ErrorCodeUnknown.WithDetail(err.Error()))
+			return
+		}
+		defer f.Close()
+		serveBlobContent(w, r, dgst, f)
+		return
+	}
+
+	if err := c.fetchBlob(name, dgst, w); err != nil {
+		logrus.Debugf("Registry cache failed to fetch blob %s of %s: %v", dgst, name, err)
+		if _, ok := err.(blobUnknownError); ok {
+			errcode.ServeJSON(w, v2.ErrorCodeBlobUnknown.WithDetail(err.Error()))
+		}
+		// Otherwise the response was already started: the client notices
+		// the missing data, and a bad digest when it verifies the blob.
+	}
+}
+
+// blobUnknownError is returned by fetchBlob when the blob could not be
+// opened, before anything is written to the client.
+type blobUnknownError struct {
+	err error
+}
+
+func (e blobUnknownError) Error() string {
+	return e.err.Error()
+}
+
+// fetchBlob downloads a blob into the cache, copying it to w on the way when
+// w is not nil.
+func (c *Cache) fetchBlob(name reference.Named, dgst digest.Digest, w http.ResponseWriter) error {
+	rc, err := c.upstream.OpenBlob(context.Background(), name, dgst)
+	if err != nil {
+		return blobUnknownError{err}
+	}
+	defer rc.Close()
+
+	tmp, err := c.store.tempFile()
+	if err != nil {
+		return blobUnknownError{err}
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	verifier, err := digest.NewDigestVerifier(dgst)
+	if err != nil {
+		return blobUnknownError{err}
+	}
+
+	dst := io.MultiWriter(tmp, verifier)
+	if w != nil {
+		if size, err := blobSize(rc); err == nil {
+			w.Header().Set("Content-Length", strconv.FormatInt(size, 10))
+		}
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Docker-Content-Digest", dgst.String())
+		w.WriteHeader(http.StatusOK)
+		// Keep caching the blob when the client goes away.
+		dst = io.MultiWriter(dst, &clientWriter{w: w})
+	}
+	if _, err := io.Copy(dst, rc); err != nil {
+		return err
+	}
+	if !verifier.Verified() {
+		return fmt.Errorf("blob %s does not match its digest", dgst)
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return c.store.commitBlob(tmp.Name(), dgst)
+}
+
+// blobSize returns the size of a blob opened from a registry.
+func blobSize(rc io.ReadCloser) (int64, error) {
+	seeker, ok := rc.(io.Seeker)
+	if !ok {
+		return 0, fmt.Errorf("blob size unknown")
+	}
+	size, err := seeker.Seek(0, os.SEEK_END)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := seeker.Seek(0, os.SEEK_SET); err != nil {
+		return 0, err
+	}
+	return size, nil
+}
+
+// clientWriter writes to a client until it fails, and then discards the
+// rest of the data.
+type clientWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (cw *clientWriter) Write(p []byte) (int, error) {
+	if cw.err == nil {
+		_, cw.err = cw.w.Write(p)
+	}
+	return len(p), nil
+}
+
+func serveBlobContent(w http.ResponseWriter, r *http.Request, dgst digest.Digest, content io.ReadSeeker) {
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Docker-Content-Digest", dgst.String())
+	w.Header().Set("Etag", fmt.Sprintf(`"%s"`, dgst))
+	http.ServeContent(w, r, "", time.Time{}, content)
+}
@@ -0,0 +1,197 @@
+package proxy
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/digest"
+	"github.com/docker/docker/reference"
+	"golang.org/x/net/context"
+)
+
+const testMediaType = "application/vnd.docker.distribution.manifest.v2+json"
+
+type fakeUpstream struct {
+	manifests map[string][]byte
+	blobs     map[digest.Digest][]byte
+	offline   bool
+	blobOpens int
+}
+
+func (u *fakeUpstream) Manifest(ctx context.Context, name reference.Named, tagOrDigest string) (distribution.Descriptor, []byte, error) {
+	if u.offline {
+		return distribution.Descriptor{}, nil, errors.New("offline")
+	}
+	payload, ok := u.manifests[name.RemoteName()+":"+tagOrDigest]
+	if !ok {
+		return distribution.Descriptor{}, nil, errors.New("manifest unknown")
+	}
+	return distribution.Descriptor{MediaType: testMediaType, Digest: digest.FromBytes(payload), Size: int64(len(payload))}, payload, nil
+}
+
+func (u *fakeUpstream) StatBlob(ctx context.Context, name reference.Named, dgst digest.Digest) (distribution.Descriptor, error) {
+	if u.offline {
+		return distribution.Descriptor{}, errors.New("offline")
+	}
+	data, ok := u.blobs[dgst]
+	if !ok {
+		return distribution.Descriptor{}, errors.New("blob unknown")
+	}
+	return distribution.Descriptor{Digest: dgst, Size: int64(len(data))}, nil
+}
+
+func (u *fakeUpstream) OpenBlob(ctx context.Context, name reference.Named, dgst digest.Digest) (io.ReadCloser, error) {
+	if u.offline {
+		return nil, errors.New("offline")
+	}
+	data, ok := u.blobs[dgst]
+	if !ok {
+		return nil, errors.New("blob unknown")
+	}
+	u.blobOpens++
+	return ioutil.NopCloser(bytes.NewReader(data)), nil
+}
+
+func newTestCache(t *testing.T, u upstream) (*httptest.Server, func()) {
+	root, err := ioutil.TempDir("", "registry-cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := newCache(root, u)
+	if err != nil {
+		os.RemoveAll(root)
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(c)
+	return server, func() {
+		server.Close()
+		os.RemoveAll(root)
+	}
+}
+
+func get(t *testing.T, method, url string, header http.Header) (*http.Response, []byte) {
+	req, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp, body
+}
+
+func TestCacheManifests(t *testing.T) {
+	payload := []byte(`{"schemaVersion": 2}`)
+	dgst := digest.FromBytes(payload)
+	u := &fakeUpstream{manifests: map[string][]byte{
+		"library/busybox:latest":           payload,
+		"library/busybox:" + dgst.String(): payload,
+	}}
+	server, cleanup := newTestCache(t, u)
+	defer cleanup()
+
+	resp, _ := get(t, "GET", server.URL+"/v2/", nil)
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Docker-Distribution-API-Version") != "registry/2.0" {
+		t.Fatalf("unexpected response to the version check: %s", resp.Status)
+	}
+
+	resp, body := get(t, "GET", server.URL+"/v2/library/busybox/manifests/latest", nil)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %s", resp.Status)
+	}
+	if !bytes.Equal(body, payload) {
+		t.Fatalf("expected %q, got %q", payload, body)
+	}
+	if resp.Header.Get("Docker-Content-Digest") != dgst.String() || resp.Header.Get("Content-Type") != testMediaType {
+		t.Fatalf("unexpected headers %v", resp.Header)
+	}
+
+	// The manifests are served from the cache once Docker Hub is offline.
+	u.offline = true
+	for _, ref := range []string{"latest", dgst.String()} {
+		resp, body = get(t, "GET", server.URL+"/v2/library/busybox/manifests/"+ref, nil)
+		if resp.StatusCode != http.StatusOK || !bytes.Equal(body, payload) {
+			t.Fatalf("%s: expected the cached manifest, got %s %q", ref, resp.Status, body)
+		}
+	}
+
+	resp, _ = get(t, "GET", server.URL+"/v2/library/busybox/manifests/unknown", nil)
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected 404 for an unknown tag, got %s", resp.Status)
+	}
+	resp, _ = get(t, "GET", server.URL+"/v2/example.com/busybox/manifests/latest", nil)
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected 404 for a repository of another registry, got %s", resp.Status)
+	}
+	resp, _ = get(t, "DELETE", server.URL+"/v2/library/busybox/manifests/latest", nil)
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Fatalf("expected 405 for a delete, got %s", resp.Status)
+	}
+}
+
+func TestCacheBlobs(t *testing.T) {
+	data := []byte("layer data")
+	dgst := digest.FromBytes(data)
+	u := &fakeUpstream{blobs: map[digest.Digest][]byte{dgst: data}}
+	server, cleanup := newTestCache(t, u)
+	defer cleanup()
+	url := server.URL + "/v2/library/busybox/blobs/" + dgst.String()
+
+	resp, body := get(t, "HEAD", url, nil)
+	if resp.StatusCode != http.StatusOK || resp.ContentLength != int64(len(data)) {
+		t.Fatalf("unexpected response to a head: %s, length %d", resp.Status, resp.ContentLength)
+	}
+	if u.blobOpens != 0 {
+		t.Fatal("expected a head not to fetch the blob")
+	}
+
+	resp, body = get(t, "GET", url, nil)
+	if resp.StatusCode != http.StatusOK || !bytes.Equal(body, data) {
+		t.Fatalf("expected the blob, got %s %q", resp.Status, body)
+	}
+
+	// The cached blob is served without Docker Hub, range requests included.
+	u.offline = true
+	resp, body = get(t, "GET", url, http.Header{"Range": {"bytes=6-"}})
+	if resp.StatusCode != http.StatusPartialContent || string(body) != "data" {
+		t.Fatalf("expected a part of the cached blob, got %s %q", resp.Status, body)
+	}
+	if u.blobOpens != 1 {
+		t.Fatalf("expected the blob to be fetched once, got %d", u.blobOpens)
+	}
+
+	resp, _ = get(t, "GET", server.URL+"/v2/library/busybox/blobs/"+digest.FromBytes([]byte("other")).String(), nil)
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected 404 for an unknown blob, got %s", resp.Status)
+	}
+}
+
+func TestCacheCorruptBlob(t *testing.T) {
+	dgst := digest.FromBytes([]byte("layer data"))
+	u := &fakeUpstream{blobs: map[digest.Digest][]byte{dgst: []byte("corrupted!")}}
+	server, cleanup := newTestCache(t, u)
+	defer cleanup()
+	url := server.URL + "/v2/library/busybox/blobs/" + dgst.String()
+
+	get(t, "GET", url, nil)
+	get(t, "GET", url, nil)
+	if u.blobOpens != 2 {
+		t.Fatalf("expected a corrupt blob not to be cached, got %d fetches", u.blobOpens)
+	}
+}
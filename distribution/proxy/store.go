@@ -0,0 +1,125 @@
+package proxy
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/docker/distribution/digest"
+)
+
+// store keeps the blobs and manifests of the cache on disk, addressed by
+// their digests, along with the digests the tags last pointed to.
+type store struct {
+	root string
+}
+
+// cachedManifest is a manifest as stored on disk.
+type cachedManifest struct {
+	MediaType string
+	Payload   []byte
+}
+
+func newStore(root string) (*store, error) {
+	// Drop the blobs left partially downloaded by a previous run.
+	if err := os.RemoveAll(filepath.Join(root, "tmp")); err != nil {
+		return nil, err
+	}
+	for _, dir := range []string{"blobs", "manifests", "tags", "tmp"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0700); err != nil {
+			return nil, err
+		}
+	}
+	return &store{root: root}, nil
+}
+
+func (s *store) blobPath(dgst digest.Digest) string {
+	return filepath.Join(s.root, "blobs", string(dgst.Algorithm()), dgst.Hex())
+}
+
+func (s *store) manifestPath(dgst digest.Digest) string {
+	return filepath.Join(s.root, "manifests", string(dgst.Algorithm()), dgst.Hex())
+}
+
+func (s *store) tagPath(name, tag string) string {
+	return filepath.Join(s.root, "tags", filepath.FromSlash(name), tag)
+}
+
+// openBlob opens a cached blob.
+func (s *store) openBlob(dgst digest.Digest) (*os.File, error) {
+	return os.Open(s.blobPath(dgst))
+}
+
+// tempFile creates a temporary file, to be committed once it holds a whole
+// blob.
+func (s *store) tempFile() (*os.File, error) {
+	return ioutil.TempFile(filepath.Join(s.root, "tmp"), "blob")
+}
+
+// commitBlob moves a downloaded and verified blob into the cache.
+func (s *store) commitBlob(tmp string, dgst digest.Digest) error {
+	path := s.blobPath(dgst)
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// manifest returns a cached manifest.
+func (s *store) manifest(dgst digest.Digest) (string, []byte, error) {
+	data, err := ioutil.ReadFile(s.manifestPath(dgst))
+	if err != nil {
+		return "", nil, err
+	}
+	var m cachedManifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return "", nil, err
+	}
+	return m.MediaType, m.Payload, nil
+}
+
+// putManifest caches a manifest.
+func (s *store) putManifest(dgst digest.Digest, mediaType string, payload []byte) error {
+	data, err := json.Marshal(cachedManifest{MediaType: mediaType, Payload: payload})
+	if err != nil {
+		return err
+	}
+	return s.writeFile(s.manifestPath(dgst), data)
+}
+
+// tag returns the digest a tag last pointed to.
+func (s *store) tag(name, tag string) (digest.Digest, error) {
+	data, err := ioutil.ReadFile(s.tagPath(name, tag))
+	if err != nil {
+		return "", err
+	}
+	return digest.ParseDigest(strings.TrimSpace(string(data)))
+}
+
+// setTag records the digest a tag points to.
+func (s *store) setTag(name, tag string, dgst digest.Digest) error {
+	return s.writeFile(s.tagPath(name, tag), []byte(dgst))
+}
+
+// writeFile writes a file atomically.
+func (s *store) writeFile(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(filepath.Join(s.root, "tmp"), "file")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
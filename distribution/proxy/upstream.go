@@ -0,0 +1,109 @@
+package proxy
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/digest"
+	"github.com/docker/distribution/registry/client"
+	dockerdist "github.com/docker/docker/distribution"
+	"github.com/docker/docker/reference"
+	"github.com/docker/docker/registry"
+	"github.com/docker/engine-api/types"
+	"golang.org/x/net/context"
+)
+
+// upstream is the registry the cache pulls through.
+type upstream interface {
+	// Manifest fetches a manifest by tag or digest, and returns its
+	// descriptor and payload.
+	Manifest(ctx context.Context, name reference.Named, tagOrDigest string) (distribution.Descriptor, []byte, error)
+	// StatBlob returns the descriptor of a blob.
+	StatBlob(ctx context.Context, name reference.Named, dgst digest.Digest) (distribution.Descriptor, error)
+	// OpenBlob opens a blob.
+	OpenBlob(ctx context.Context, name reference.Named, dgst digest.Digest) (io.ReadCloser, error)
+}
+
+// registryUpstream pulls from the endpoints of a registry, leaving out its
+// mirrors, with the credentials of the credential helper of the registry.
+type registryUpstream struct {
+	service *registry.Service
+}
+
+// repository connects to the first v2 endpoint of a repository that
+// answers.
+func (u *registryUpstream) repository(ctx context.Context, name reference.Named) (distribution.Repository, error) {
+	repoInfo, err := u.service.ResolveRepository(name)
+	if err != nil {
+		return nil, err
+	}
+	authConfig := u.service.ResolveCredentials(repoInfo.Index, &types.AuthConfig{})
+
+	endpoints, err := u.service.LookupPushEndpoints(repoInfo.Hostname())
+	if err != nil {
+		return nil, err
+	}
+	lastErr := fmt.Errorf("no v2 endpoint found for %s", repoInfo.Hostname())
+	for _, endpoint := range endpoints {
+		if endpoint.Version != registry.APIVersion2 {
+			continue
+		}
+		repo, _, err := dockerdist.NewV2Repository(ctx, repoInfo, endpoint, nil, authConfig, "pull")
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		return repo, nil
+	}
+	return nil, lastErr
+}
+
+func (u *registryUpstream) Manifest(ctx context.Context, name reference.Named, tagOrDigest string) (distribution.Descriptor, []byte, error) {
+	repo, err := u.repository(ctx, name)
+	if err != nil {
+		return distribution.Descriptor{}, nil, err
+	}
+	manifests, err := repo.Manifests(ctx)
+	if err != nil {
+		return distribution.Descriptor{}, nil, err
+	}
+
+	var m distribution.Manifest
+	if dgst, err := digest.ParseDigest(tagOrDigest); err == nil {
+		m, err = manifests.Get(ctx, dgst)
+	} else {
+		m, err = manifests.Get(ctx, "", client.WithTag(tagOrDigest))
+	}
+	if err != nil {
+		return distribution.Descriptor{}, nil, err
+	}
+	mediaType, payload, err := m.Payload()
+	if err != nil {
+		return distribution.Descriptor{}, nil, err
+	}
+	// Unmarshal the payload again for its digest, which does not cover the
+	// signatures of schema1 manifests.
+	_, desc, err := distribution.UnmarshalManifest(mediaType, payload)
+	if err != nil {
+		return distribution.Descriptor{}, nil, err
+	}
+	desc.MediaType = mediaType
+	return desc, payload, nil
+}
+
+func (u *registryUpstream) StatBlob(ctx context.Context, name reference.Named, dgst digest.Digest) (distribution.Descriptor, error) {
+	repo, err := u.repository(ctx, name)
+	if err != nil {
+		return distribution.Descriptor{}, err
+	}
+	return repo.Blobs(ctx).Stat(ctx, dgst)
+}
+
+func (u *registryUpstream) OpenBlob(ctx context.Context, name reference.Named, dgst digest.Digest) (io.ReadCloser, error) {
+	repo, err := u.repository(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return repo.Blobs(ctx).Open(ctx, dgst)
+}
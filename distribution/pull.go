@@ -25,11 +25,20 @@ type ImagePullConfig struct {
 	// ProgressOutput is the interface for showing the status of the pull
 	// operation.
 	ProgressOutput progress.Output
+	// Platform is the platform of the image pulled from a manifest list.
+	// The zero value selects the platform of the daemon.
+	Platform Platform
 	// RegistryService is the registry service to use for TLS configuration
 	// and endpoint lookup.
 	RegistryService *registry.Service
+	// RecordDigests also references the images pulled by tag by the digest
+	// of their manifest, so that the tag can later be resolved to it.
+	RecordDigests bool
 	// ImageEventLogger notifies events for a given image
 	ImageEventLogger func(id, name, action string)
+	// RateLimitEventLogger, if set, notifies the rate limits reported by
+	// the registry while pulling the repository of a given name.
+	RateLimitEventLogger func(name string, limit registry.RateLimit)
 	// MetadataStore is the storage backend for distribution-specific
 	// metadata.
 	MetadataStore metadata.Store
@@ -39,6 +48,10 @@ type ImagePullConfig struct {
 	ReferenceStore reference.Store
 	// DownloadManager manages concurrent pulls.
 	DownloadManager *xfer.LayerDownloadManager
+	// DownloadsDir is where the partial layer downloads are kept, so that
+	// the next pull of a layer resumes its download after a restart of
+	// the daemon. Layers are downloaded to temporary files if it is empty.
+	DownloadsDir string
 }
 
 // Puller is an interface that abstracts pulling for different API versions.
@@ -83,6 +96,13 @@ func Pull(ctx context.Context, ref reference.Named, imagePullConfig *ImagePullCo
 		return err
 	}
 
+	// Fall back to the daemon's credential helper for pulls without credentials
+	if authConfig := imagePullConfig.RegistryService.ResolveCredentials(repoInfo.Index, imagePullConfig.AuthConfig); authConfig != imagePullConfig.AuthConfig {
+		config := *imagePullConfig
+		config.AuthConfig = authConfig
+		imagePullConfig = &config
+	}
+
 	// makes sure name is not empty or `scratch`
 	if err := validateRepoName(repoInfo.Name()); err != nil {
 		return err
@@ -142,6 +162,7 @@ func Pull(ctx context.Context, ref reference.Named, imagePullConfig *ImagePullCo
 			select {
 			case <-ctx.Done():
 			default:
+				imagePullConfig.RegistryService.ReportMirrorPull(endpoint, err)
 				if fallbackErr, ok := err.(fallbackError); ok {
 					fallback = true
 					confirmedV2 = confirmedV2 || fallbackErr.confirmedV2
@@ -170,6 +191,7 @@ func Pull(ctx context.Context, ref reference.Named, imagePullConfig *ImagePullCo
 			return err
 		}
 
+		imagePullConfig.RegistryService.ReportMirrorPull(endpoint, nil)
 		imagePullConfig.ImageEventLogger(ref.String(), repoInfo.Name(), "pull")
 		return nil
 	}
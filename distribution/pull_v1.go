@@ -287,6 +287,10 @@ func (ld *v1LayerDescriptor) Key() string {
 	return "v1:" + ld.v1LayerID
 }
 
+func (ld *v1LayerDescriptor) Registry() string {
+	return ld.indexName
+}
+
 func (ld *v1LayerDescriptor) ID() string {
 	return stringid.TruncateID(ld.v1LayerID)
 }
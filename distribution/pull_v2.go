@@ -59,7 +59,8 @@ type v2Puller struct {
 
 func (p *v2Puller) Pull(ctx context.Context, ref reference.Named) (err error) {
 	// TODO(tiborvass): was ReceiveTimeout
-	p.repo, p.confirmedV2, err = NewV2Repository(ctx, p.repoInfo, p.endpoint, p.config.MetaHeaders, p.config.AuthConfig, "pull")
+	rateLimits := newRateLimitReporter(p.repoInfo.Name(), p.config)
+	p.repo, p.confirmedV2, err = newV2Repository(ctx, p.repoInfo, p.endpoint, p.config.MetaHeaders, p.config.AuthConfig, rateLimits.observe, "pull")
 	if err != nil {
 		logrus.Warnf("Error getting v2 registry: %v", err)
 		return err
@@ -134,6 +135,13 @@ type v2LayerDescriptor struct {
 	V2MetadataService *metadata.V2MetadataService
 	tmpFile           *os.File
 	verifier          digest.Verifier
+	rateLimiter       *xfer.RateLimiter
+	// downloadsDir is where the partial downloads are kept, to resume
+	// them after a restart of the daemon. Layers are downloaded to
+	// temporary files if it is empty.
+	downloadsDir string
+	// downloaded is set once the layer is downloaded and verified.
+	downloaded bool
 }
 
 func (ld *v2LayerDescriptor) Key() string {
@@ -144,6 +152,10 @@ func (ld *v2LayerDescriptor) ID() string {
 	return stringid.TruncateID(ld.digest.String())
 }
 
+func (ld *v2LayerDescriptor) Registry() string {
+	return ld.repoInfo.Index.Name
+}
+
 func (ld *v2LayerDescriptor) DiffID() (layer.DiffID, error) {
 	return ld.V2MetadataService.GetDiffID(ld.digest)
 }
@@ -157,30 +169,40 @@ func (ld *v2LayerDescriptor) Download(ctx context.Context, progressOutput progre
 	)
 
 	if ld.tmpFile == nil {
-		ld.tmpFile, err = createDownloadFile()
+		ld.tmpFile, ld.verifier, err = ld.createDownloadFile()
 		if err != nil {
 			return nil, 0, xfer.DoNotRetry{Err: err}
 		}
-	} else {
-		offset, err = ld.tmpFile.Seek(0, os.SEEK_END)
-		if err != nil {
-			logrus.Debugf("error seeking to end of download file: %v", err)
-			offset = 0
+	}
+	offset, err = ld.tmpFile.Seek(0, os.SEEK_END)
+	if err != nil {
+		logrus.Debugf("error seeking to end of download file: %v", err)
+		offset = 0
 
-			ld.tmpFile.Close()
-			if err := os.Remove(ld.tmpFile.Name()); err != nil {
-				logrus.Errorf("Failed to remove temp file: %s", ld.tmpFile.Name())
-			}
-			ld.tmpFile, err = createDownloadFile()
-			if err != nil {
-				return nil, 0, xfer.DoNotRetry{Err: err}
-			}
-		} else if offset != 0 {
-			logrus.Debugf("attempting to resume download of %q from %d bytes", ld.digest, offset)
+		ld.tmpFile.Close()
+		if err := os.Remove(ld.tmpFile.Name()); err != nil {
+			logrus.Errorf("Failed to remove temp file: %s", ld.tmpFile.Name())
+		}
+		ld.tmpFile, ld.verifier, err = ld.createDownloadFile()
+		if err != nil {
+			return nil, 0, xfer.DoNotRetry{Err: err}
 		}
+	} else if offset != 0 {
+		logrus.Debugf("attempting to resume download of %q from %d bytes", ld.digest, offset)
 	}
 
 	tmpFile := ld.tmpFile
+
+	// The download may have completed before a restart of the daemon.
+	if offset != 0 && ld.verifier != nil && ld.verifier.Verified() {
+		if _, err := tmpFile.Seek(0, os.SEEK_SET); err != nil {
+			return nil, 0, xfer.DoNotRetry{Err: err}
+		}
+		ld.downloaded = true
+		progress.Update(progressOutput, ld.ID(), "Download complete")
+		return tmpFile, offset, nil
+	}
+
 	blobs := ld.repo.Blobs(ctx)
 
 	layerDownload, err := blobs.Open(ctx, ld.digest)
@@ -225,7 +247,7 @@ func (ld *v2LayerDescriptor) Download(ctx context.Context, progressOutput progre
 		}
 	}
 
-	reader := progress.NewProgressReader(ioutils.NewCancelReadCloser(ctx, layerDownload), progressOutput, size-offset, ld.ID(), "Downloading")
+	reader := progress.NewProgressReader(ioutils.NewCancelReadCloser(ctx, ld.rateLimiter.Limit(ctx, layerDownload)), progressOutput, size-offset, ld.ID(), "Downloading")
 	defer reader.Close()
 
 	if ld.verifier == nil {
@@ -261,9 +283,12 @@ func (ld *v2LayerDescriptor) Download(ctx context.Context, progressOutput progre
 
 			return nil, 0, err
 		}
+		// Do not resume from corrupted data on the next pull.
+		ld.truncateDownloadFile()
 		return nil, 0, xfer.DoNotRetry{Err: err}
 	}
 
+	ld.downloaded = true
 	progress.Update(progressOutput, ld.ID(), "Download complete")
 
 	logrus.Debugf("Downloaded %s to tempfile %s", ld.ID(), tmpFile.Name())
@@ -284,6 +309,10 @@ func (ld *v2LayerDescriptor) Download(ctx context.Context, progressOutput progre
 func (ld *v2LayerDescriptor) Close() {
 	if ld.tmpFile != nil {
 		ld.tmpFile.Close()
+		if ld.downloadsDir != "" && !ld.downloaded {
+			// Keep the partial download to resume it on the next pull.
+			return
+		}
 		if err := os.RemoveAll(ld.tmpFile.Name()); err != nil {
 			logrus.Errorf("Failed to remove temp file: %s", ld.tmpFile.Name())
 		}
@@ -379,6 +408,16 @@ func (p *v2Puller) pullV2Tag(ctx context.Context, ref reference.Named) (tagUpdat
 
 	progress.Message(p.config.ProgressOutput, "", "Digest: "+manifestDigest.String())
 
+	if _, ok := ref.(reference.Canonical); !ok && p.config.RecordDigests {
+		canonical, err := reference.WithDigest(ref, manifestDigest)
+		if err != nil {
+			return false, err
+		}
+		if err := p.config.ReferenceStore.AddDigest(canonical, imageID, true); err != nil {
+			return false, err
+		}
+	}
+
 	oldTagImageID, err := p.config.ReferenceStore.Get(ref)
 	if err == nil {
 		if oldTagImageID == imageID {
@@ -450,6 +489,8 @@ func (p *v2Puller) pullSchema1(ctx context.Context, ref reference.Named, unverif
 			repoInfo:          p.repoInfo,
 			repo:              p.repo,
 			V2MetadataService: p.V2MetadataService,
+			rateLimiter:       p.config.DownloadManager.RateLimiter(p.repoInfo.Index.Name),
+			downloadsDir:      p.config.DownloadsDir,
 		}
 
 		descriptors = append(descriptors, layerDescriptor)
@@ -484,9 +525,14 @@ func (p *v2Puller) pullSchema2(ctx context.Context, ref reference.Named, mfst *s
 
 	target := mfst.Target()
 	imageID = image.ID(target.Digest)
-	if _, err := p.config.ImageStore.Get(imageID); err == nil {
+	if img, err := p.config.ImageStore.Get(imageID); err == nil {
 		// If the image already exists locally, no need to pull
 		// anything.
+		if p.config.Platform.OS != "" {
+			if err := checkImagePlatform(img.RawJSON(), p.config.Platform); err != nil {
+				return "", "", err
+			}
+		}
 		return imageID, manifestDigest, nil
 	}
 
@@ -516,6 +562,8 @@ func (p *v2Puller) pullSchema2(ctx context.Context, ref reference.Named, mfst *s
 			repo:              p.repo,
 			repoInfo:          p.repoInfo,
 			V2MetadataService: p.V2MetadataService,
+			rateLimiter:       p.config.DownloadManager.RateLimiter(p.repoInfo.Index.Name),
+			downloadsDir:      p.config.DownloadsDir,
 		}
 
 		descriptors = append(descriptors, layerDescriptor)
@@ -567,6 +615,12 @@ func (p *v2Puller) pullSchema2(ctx context.Context, ref reference.Named, mfst *s
 		}
 	}
 
+	if p.config.Platform.OS != "" {
+		if err := checkImagePlatform(configJSON, p.config.Platform); err != nil {
+			return "", "", err
+		}
+	}
+
 	// The DiffIDs returned in rootFS MUST match those in the config.
 	// Otherwise the image config could be referencing layers that aren't
 	// included in the manifest.
@@ -611,19 +665,11 @@ func (p *v2Puller) pullManifestList(ctx context.Context, ref reference.Named, mf
 		return "", "", err
 	}
 
-	var manifestDigest digest.Digest
-	for _, manifestDescriptor := range mfstList.Manifests {
-		// TODO(aaronl): The manifest list spec supports optional
-		// "features" and "variant" fields. These are not yet used.
-		// Once they are, their values should be interpreted here.
-		if manifestDescriptor.Platform.Architecture == runtime.GOARCH && manifestDescriptor.Platform.OS == runtime.GOOS {
-			manifestDigest = manifestDescriptor.Digest
-			break
-		}
-	}
-
-	if manifestDigest == "" {
-		return "", "", errors.New("no supported platform found in manifest list")
+	// TODO(aaronl): The manifest list spec supports an optional
+	// "features" field. It is not yet used.
+	manifestDigest, err := selectManifest(mfstList, p.config.Platform.orDefault())
+	if err != nil {
+		return "", "", err
 	}
 
 	manSvc, err := p.repo.Manifests(ctx)
@@ -824,6 +870,12 @@ func fixManifestLayers(m *schema1.Manifest) error {
 	return nil
 }
 
-func createDownloadFile() (*os.File, error) {
-	return ioutil.TempFile("", "GetImageBlob")
+// createDownloadFile opens the file the layer is downloaded to, along with a
+// verifier of the data it already holds, if any.
+func (ld *v2LayerDescriptor) createDownloadFile() (*os.File, digest.Verifier, error) {
+	if ld.downloadsDir != "" {
+		return openPartialDownload(ld.downloadsDir, ld.digest)
+	}
+	f, err := ioutil.TempFile("", "GetImageBlob")
+	return f, nil, err
 }
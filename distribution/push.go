@@ -100,6 +100,13 @@ func Push(ctx context.Context, ref reference.Named, imagePushConfig *ImagePushCo
 		return err
 	}
 
+	// Fall back to the daemon's credential helper for pushes without credentials
+	if authConfig := imagePushConfig.RegistryService.ResolveCredentials(repoInfo.Index, imagePushConfig.AuthConfig); authConfig != imagePushConfig.AuthConfig {
+		config := *imagePushConfig
+		config.AuthConfig = authConfig
+		imagePushConfig = &config
+	}
+
 	endpoints, err := imagePushConfig.RegistryService.LookupPushEndpoints(repoInfo.Hostname())
 	if err != nil {
 		return err
@@ -139,6 +139,7 @@ func (p *v2Pusher) pushV2Tag(ctx context.Context, ref reference.NamedTagged, ima
 		repoInfo:          p.repoInfo,
 		repo:              p.repo,
 		pushState:         &p.pushState,
+		rateLimiter:       p.config.UploadManager.RateLimiter(p.repoInfo.Hostname()),
 	}
 
 	// Loop bounds condition is to avoid pushing the base layer on Windows.
@@ -225,6 +226,7 @@ type v2PushDescriptor struct {
 	repo              distribution.Repository
 	pushState         *pushState
 	remoteDescriptor  distribution.Descriptor
+	rateLimiter       *xfer.RateLimiter
 }
 
 func (pd *v2PushDescriptor) Key() string {
@@ -235,6 +237,10 @@ func (pd *v2PushDescriptor) ID() string {
 	return stringid.TruncateID(pd.layer.DiffID().String())
 }
 
+func (pd *v2PushDescriptor) Registry() string {
+	return pd.repoInfo.Hostname()
+}
+
 func (pd *v2PushDescriptor) DiffID() layer.DiffID {
 	return pd.layer.DiffID()
 }
@@ -367,7 +373,7 @@ func (pd *v2PushDescriptor) Upload(ctx context.Context, progressOutput progress.
 	}()
 
 	digester := digest.Canonical.New()
-	tee := io.TeeReader(compressedReader, digester.Hash())
+	tee := io.TeeReader(pd.rateLimiter.Limit(ctx, compressedReader), digester.Hash())
 
 	nn, err := layerUpload.ReadFrom(tee)
 	compressedReader.Close()
@@ -0,0 +1,51 @@
+package distribution
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/docker/docker/pkg/progress"
+	"github.com/docker/docker/registry"
+)
+
+// rateLimitReporter shows the rate limits reported by a registry during a
+// pull in the progress output, and logs them as events. A quota is only
+// reported when it changes, as the layers of a pull are downloaded
+// concurrently and every response carries it.
+type rateLimitReporter struct {
+	name           string
+	progressOutput progress.Output
+	eventLogger    func(name string, limit registry.RateLimit)
+
+	mu       sync.Mutex
+	reported bool
+	last     registry.RateLimit
+}
+
+func newRateLimitReporter(name string, config *ImagePullConfig) *rateLimitReporter {
+	return &rateLimitReporter{
+		name:           name,
+		progressOutput: config.ProgressOutput,
+		eventLogger:    config.RateLimitEventLogger,
+	}
+}
+
+func (r *rateLimitReporter) observe(limit registry.RateLimit) {
+	r.mu.Lock()
+	changed := !r.reported || limit != r.last
+	r.reported = true
+	r.last = limit
+	r.mu.Unlock()
+	if !changed {
+		return
+	}
+
+	if limit.RetryAfter > 0 {
+		progress.Message(r.progressOutput, "", fmt.Sprintf("Too many requests, retrying in %s", limit.RetryAfter))
+	} else {
+		progress.Message(r.progressOutput, "", fmt.Sprintf("Rate limit: %d of %d pulls remaining", limit.Remaining, limit.Limit))
+	}
+	if r.eventLogger != nil {
+		r.eventLogger(r.name, limit)
+	}
+}
@@ -0,0 +1,208 @@
+package distribution
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/distribution/registry/client/auth"
+	"github.com/docker/docker/registry"
+	"github.com/docker/engine-api/types"
+)
+
+// tokenHandlerIdleTimeout is the time after which the token handlers that
+// were not used are dropped from the cache.
+const tokenHandlerIdleTimeout = time.Hour
+
+// tokenCacheKey identifies the tokens of a set of credentials for a scope of
+// a registry.
+type tokenCacheKey struct {
+	registry    string
+	scope       string
+	credentials string
+}
+
+// cachedTokenHandler is a token handler kept in a tokenCache. The handler
+// caches its token until it expires, and the tokens of its scope are
+// refreshed with the refresh tokens it was given.
+type cachedTokenHandler struct {
+	handler   auth.AuthenticationHandler
+	transport *tokenTransport
+	lastUsed  time.Time
+}
+
+// tokenCache shares the bearer tokens of registries between the pulls and
+// pushes, and between the layers of a pull or push, so that a token is only
+// requested again from the authorization server when it expires.
+type tokenCache struct {
+	mu       sync.Mutex
+	handlers map[tokenCacheKey]*cachedTokenHandler
+}
+
+// tokens is the token cache of the daemon.
+var tokens = newTokenCache()
+
+func newTokenCache() *tokenCache {
+	return &tokenCache{handlers: make(map[tokenCacheKey]*cachedTokenHandler)}
+}
+
+// handler returns the token handler of a scope of a registry, creating it
+// if needed. The token requests go through transport.
+func (c *tokenCache) handler(key tokenCacheKey, transport http.RoundTripper, authConfig *types.AuthConfig, scopes []auth.Scope) auth.AuthenticationHandler {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if cached, ok := c.handlers[key]; ok {
+		cached.lastUsed = now
+		cached.transport.set(transport)
+		return cached.handler
+	}
+
+	for k, cached := range c.handlers {
+		if now.Sub(cached.lastUsed) > tokenHandlerIdleTimeout {
+			delete(c.handlers, k)
+		}
+	}
+	tr := &tokenTransport{rt: transport}
+	handler := auth.NewTokenHandlerWithOptions(auth.TokenHandlerOptions{
+		Transport:   tr,
+		Credentials: &refreshingCredentialStore{auth: authConfig},
+		Scopes:      scopes,
+		ClientID:    registry.AuthClientID,
+	})
+	c.handlers[key] = &cachedTokenHandler{handler: handler, transport: tr, lastUsed: now}
+	return handler
+}
+
+// tokenTransport is the transport of a cached token handler, which is the
+// one of the last pull or push using it, as TLS configurations change.
+type tokenTransport struct {
+	mu sync.Mutex
+	rt http.RoundTripper
+}
+
+func (t *tokenTransport) set(rt http.RoundTripper) {
+	t.mu.Lock()
+	t.rt = rt
+	t.mu.Unlock()
+}
+
+func (t *tokenTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.mu.Lock()
+	rt := t.rt
+	t.mu.Unlock()
+	return rt.RoundTrip(req)
+}
+
+// refreshingCredentialStore is a credential store keeping the refresh
+// tokens returned by the authorization servers, and using them instead of
+// the identity token of the credentials once it has some.
+type refreshingCredentialStore struct {
+	auth *types.AuthConfig
+
+	mu            sync.Mutex
+	refreshTokens map[string]string
+}
+
+func (cs *refreshingCredentialStore) Basic(*url.URL) (string, string) {
+	return cs.auth.Username, cs.auth.Password
+}
+
+func (cs *refreshingCredentialStore) RefreshToken(realm *url.URL, service string) string {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	if token, ok := cs.refreshTokens[realm.String()+" "+service]; ok {
+		return token
+	}
+	return cs.auth.IdentityToken
+}
+
+func (cs *refreshingCredentialStore) SetRefreshToken(realm *url.URL, service, token string) {
+	// Refresh tokens are only used in place of identity tokens, requesting
+	// one with a password is not supported by every registry.
+	if cs.auth.IdentityToken == "" {
+		return
+	}
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	if cs.refreshTokens == nil {
+		cs.refreshTokens = make(map[string]string)
+	}
+	cs.refreshTokens[realm.String()+" "+service] = token
+}
+
+// scopedTokenHandler authorizes the requests to a repository with the
+// cached token of their scope. Requests mounting a blob from another
+// repository need a token for both repositories, which is cached too.
+type scopedTokenHandler struct {
+	cache      *tokenCache
+	registry   string
+	repository string
+	actions    []string
+	transport  http.RoundTripper
+	authConfig *types.AuthConfig
+}
+
+func newScopedTokenHandler(cache *tokenCache, endpoint registry.APIEndpoint, repository string, actions []string, transport http.RoundTripper, authConfig *types.AuthConfig) auth.AuthenticationHandler {
+	return &scopedTokenHandler{
+		cache:      cache,
+		registry:   endpoint.URL.String(),
+		repository: repository,
+		actions:    actions,
+		transport:  transport,
+		authConfig: authConfig,
+	}
+}
+
+func (th *scopedTokenHandler) Scheme() string {
+	return "bearer"
+}
+
+func (th *scopedTokenHandler) AuthorizeRequest(req *http.Request, params map[string]string) error {
+	scopes := []auth.Scope{auth.RepositoryScope{Repository: th.repository, Actions: th.actions}}
+	if from := req.URL.Query().Get("from"); from != "" {
+		scopes = append(scopes, auth.RepositoryScope{Repository: from, Actions: []string{"pull"}})
+	}
+	key := tokenCacheKey{
+		registry:    th.registry,
+		scope:       scopeString(scopes),
+		credentials: credentialsHash(th.authConfig),
+	}
+	handler := th.cache.handler(key, th.transport, th.authConfig, scopes)
+
+	// The token handlers do not cache the tokens of requests with a from
+	// parameter, it is part of their scopes instead.
+	authReq := &http.Request{URL: &url.URL{Scheme: req.URL.Scheme, Host: req.URL.Host, Path: req.URL.Path}, Header: make(http.Header)}
+	if err := handler.AuthorizeRequest(authReq, params); err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", authReq.Header.Get("Authorization"))
+	return nil
+}
+
+// scopeString returns a canonical representation of scopes.
+func scopeString(scopes []auth.Scope) string {
+	s := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		s = append(s, scope.String())
+	}
+	sort.Strings(s)
+	return strings.Join(s, " ")
+}
+
+// credentialsHash identifies credentials without keeping them in the keys
+// of the cache.
+func credentialsHash(authConfig *types.AuthConfig) string {
+	h := sha256.New()
+	for _, s := range []string{authConfig.Username, authConfig.Password, authConfig.IdentityToken} {
+		h.Write([]byte(s))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
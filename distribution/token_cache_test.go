@@ -0,0 +1,57 @@
+package distribution
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/docker/docker/registry"
+	"github.com/docker/engine-api/types"
+)
+
+func TestTokenCache(t *testing.T) {
+	var fetches int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches++
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"token":"token","expires_in":300}`))
+	}))
+	defer ts.Close()
+
+	cache := newTokenCache()
+	endpointURL, _ := url.Parse("https://registry.example.com")
+	endpoint := registry.APIEndpoint{URL: endpointURL}
+	params := map[string]string{"realm": ts.URL, "service": "registry"}
+	user := &types.AuthConfig{Username: "user", Password: "password"}
+
+	authorize := func(handler *scopedTokenHandler, rawURL string) {
+		req, _ := http.NewRequest("GET", rawURL, nil)
+		if err := handler.AuthorizeRequest(req, params); err != nil {
+			t.Fatal(err)
+		}
+		if req.Header.Get("Authorization") != "Bearer token" {
+			t.Fatalf("unexpected Authorization header %q", req.Header.Get("Authorization"))
+		}
+	}
+
+	// Layers of a push, and a second push of the same repository.
+	for i := 0; i < 2; i++ {
+		handler := newScopedTokenHandler(cache, endpoint, "library/busybox", []string{"push", "pull"}, http.DefaultTransport, user).(*scopedTokenHandler)
+		authorize(handler, "https://registry.example.com/v2/library/busybox/blobs/uploads/")
+		authorize(handler, "https://registry.example.com/v2/library/busybox/blobs/uploads/")
+		authorize(handler, "https://registry.example.com/v2/library/busybox/blobs/uploads/?mount=sha256:abc&from=library/alpine")
+		authorize(handler, "https://registry.example.com/v2/library/busybox/blobs/uploads/?mount=sha256:def&from=library/alpine")
+	}
+	if fetches != 2 {
+		t.Fatalf("expected 2 token requests, got %d", fetches)
+	}
+
+	// Tokens are not shared between credentials.
+	other := &types.AuthConfig{Username: "other", Password: "password"}
+	handler := newScopedTokenHandler(cache, endpoint, "library/busybox", []string{"push", "pull"}, http.DefaultTransport, other).(*scopedTokenHandler)
+	authorize(handler, "https://registry.example.com/v2/library/busybox/blobs/uploads/")
+	if fetches != 3 {
+		t.Fatalf("expected 3 token requests, got %d", fetches)
+	}
+}
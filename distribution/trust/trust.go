@@ -0,0 +1,294 @@
+// Package trust verifies the signatures of images against the trust data of
+// their repositories on a Notary server, so that the daemon can enforce
+// content trust whatever the client.
+package trust
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/docker/distribution/digest"
+	"github.com/docker/distribution/registry/client/auth"
+	"github.com/docker/distribution/registry/client/transport"
+	"github.com/docker/docker/dockerversion"
+	"github.com/docker/docker/reference"
+	"github.com/docker/docker/registry"
+	"github.com/docker/engine-api/types"
+	"github.com/docker/notary/client"
+	"github.com/docker/notary/passphrase"
+	"github.com/docker/notary/tuf/data"
+)
+
+var (
+	releasesRole = data.CanonicalTargetsRole + "/releases"
+
+	keyIDRegexp = regexp.MustCompile(`^[a-f0-9]{64}$`)
+)
+
+// Config configures a Verifier.
+type Config struct {
+	// Root is the directory the trust data of the repositories is kept in.
+	Root string
+	// Server is the Notary server of all the repositories. When empty,
+	// Docker Hub repositories use the Docker Hub Notary server and the
+	// others the Notary server running on the host of their registry.
+	Server string
+	// Pins maps repository names to the IDs of the root keys their trust
+	// data must be signed with. A name ending with "*" matches all the
+	// repositories starting with it.
+	Pins map[string][]string
+	// RegistryService resolves the repositories and their credentials.
+	RegistryService *registry.Service
+}
+
+// Verifier looks up the signed digests of repositories.
+type Verifier struct {
+	config Config
+}
+
+// NewVerifier creates a Verifier.
+func NewVerifier(config Config) *Verifier {
+	return &Verifier{config: config}
+}
+
+// Target is a signed tag of a repository.
+type Target struct {
+	Tag    string
+	Digest digest.Digest
+}
+
+// Resolve returns the signed digest of a tag.
+func (v *Verifier) Resolve(ref reference.NamedTagged, authConfig *types.AuthConfig) (digest.Digest, error) {
+	repo, err := v.repository(ref, authConfig)
+	if err != nil {
+		return "", err
+	}
+	t, err := repo.GetTargetByName(ref.Tag(), releasesRole, data.CanonicalTargetsRole)
+	if err != nil {
+		return "", notaryError(ref.FullName(), err)
+	}
+	target, err := convertTarget(t.Target)
+	if err != nil {
+		return "", err
+	}
+	return target.Digest, nil
+}
+
+// Targets returns the signed tags of a repository.
+func (v *Verifier) Targets(name reference.Named, authConfig *types.AuthConfig) ([]Target, error) {
+	repo, err := v.repository(name, authConfig)
+	if err != nil {
+		return nil, err
+	}
+	targets, err := repo.ListTargets(releasesRole, data.CanonicalTargetsRole)
+	if err != nil {
+		return nil, notaryError(name.FullName(), err)
+	}
+	var signed []Target
+	for _, t := range targets {
+		target, err := convertTarget(t.Target)
+		if err != nil {
+			logrus.Debugf("Skipping target %s of %s: %v", t.Name, name.FullName(), err)
+			continue
+		}
+		signed = append(signed, target)
+	}
+	return signed, nil
+}
+
+// IsSigned returns whether a digest is signed for one of the tags of a
+// repository.
+func (v *Verifier) IsSigned(name reference.Named, dgst digest.Digest, authConfig *types.AuthConfig) (bool, error) {
+	targets, err := v.Targets(name, authConfig)
+	if err != nil {
+		return false, err
+	}
+	for _, t := range targets {
+		if t.Digest == dgst {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// repository connects to the trust data of a repository, and checks that
+// its root keys are pinned, if pins are configured for it.
+func (v *Verifier) repository(name reference.Named, authConfig *types.AuthConfig) (*client.NotaryRepository, error) {
+	repoInfo, err := v.config.RegistryService.ResolveRepository(name)
+	if err != nil {
+		return nil, err
+	}
+	authConfig = v.config.RegistryService.ResolveCredentials(repoInfo.Index, authConfig)
+
+	server := v.config.Server
+	if server == "" {
+		if repoInfo.Index.Official {
+			server = registry.NotaryServer
+		} else {
+			server = "https://" + repoInfo.Index.Name
+		}
+	}
+	u, err := url.Parse(server)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig, err := v.config.RegistryService.TLSConfig(u.Host)
+	if err != nil {
+		return nil, err
+	}
+	base := registry.NewTransport(tlsConfig)
+
+	modifiers := registry.DockerHeaders(dockerversion.DockerUserAgent(), http.Header{})
+	challengeManager := auth.NewSimpleChallengeManager()
+	pingClient := &http.Client{
+		Transport: transport.NewTransport(base, modifiers...),
+		Timeout:   5 * time.Second,
+	}
+	resp, err := pingClient.Get(server + "/v2/")
+	if err != nil {
+		// Go on with the trust data cached by the previous verifications.
+		logrus.Debugf("Error pinging notary server %q: %s", server, err)
+	} else {
+		defer resp.Body.Close()
+		if err := challengeManager.AddResponse(resp); err != nil {
+			return nil, err
+		}
+	}
+
+	var creds credentialStore
+	if authConfig != nil {
+		creds.auth = *authConfig
+	}
+	tokenHandler := auth.NewTokenHandler(transport.NewTransport(base, modifiers...), creds, repoInfo.FullName(), "pull")
+	basicHandler := auth.NewBasicHandler(creds)
+	modifiers = append(modifiers, auth.NewAuthorizer(challengeManager, tokenHandler, basicHandler))
+
+	// The daemon only reads trust data, so it never needs a passphrase.
+	retriever := passphrase.ConstantRetriever("")
+	repo, err := client.NewNotaryRepository(v.config.Root, repoInfo.FullName(), server, transport.NewTransport(base, modifiers...), retriever)
+	if err != nil {
+		return nil, err
+	}
+	if err := v.checkPins(repoInfo.FullName(), repo); err != nil {
+		return nil, err
+	}
+	return repo, nil
+}
+
+// checkPins checks that the trust data of a repository is signed with one of
+// the root keys pinned for it.
+func (v *Verifier) checkPins(name string, repo *client.NotaryRepository) error {
+	pins := v.pins(name)
+	if pins == nil {
+		return nil
+	}
+	roles, err := repo.ListRoles()
+	if err != nil {
+		return notaryError(name, err)
+	}
+	for _, role := range roles {
+		if role.Name != data.CanonicalRootRole {
+			continue
+		}
+		for _, keyID := range role.KeyIDs {
+			for _, pin := range pins {
+				if keyID == pin {
+					return nil
+				}
+			}
+		}
+		return fmt.Errorf("trust data of %s is not signed with a pinned root key: root keys are %s", name, strings.Join(role.KeyIDs, ", "))
+	}
+	return fmt.Errorf("trust data of %s has no root role", name)
+}
+
+// pins returns the root keys pinned for a repository, preferring the pins
+// of its name to the pins of the longest prefix.
+func (v *Verifier) pins(name string) []string {
+	if pins, ok := v.config.Pins[name]; ok {
+		return pins
+	}
+	var prefixes []string
+	for pattern := range v.config.Pins {
+		if strings.HasSuffix(pattern, "*") && strings.HasPrefix(name, strings.TrimSuffix(pattern, "*")) {
+			prefixes = append(prefixes, pattern)
+		}
+	}
+	if len(prefixes) == 0 {
+		return nil
+	}
+	sort.Sort(sort.Reverse(byLength(prefixes)))
+	return v.config.Pins[prefixes[0]]
+}
+
+type byLength []string
+
+func (s byLength) Len() int           { return len(s) }
+func (s byLength) Less(i, j int) bool { return len(s[i]) < len(s[j]) }
+func (s byLength) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+// ParsePin parses a repository=keyID,... pin, and returns the repository
+// name, normalized unless it is a prefix, along with the key IDs.
+func ParsePin(val string) (string, []string, error) {
+	parts := strings.SplitN(val, "=", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", nil, fmt.Errorf("invalid trust pin %q, expected repository=keyID,...", val)
+	}
+	name := parts[0]
+	if !strings.HasSuffix(name, "*") {
+		named, err := reference.WithName(name)
+		if err != nil {
+			return "", nil, fmt.Errorf("invalid trust pin %q: %v", val, err)
+		}
+		name = named.FullName()
+	}
+	var keyIDs []string
+	for _, keyID := range strings.Split(parts[1], ",") {
+		keyID = strings.TrimSpace(keyID)
+		if !keyIDRegexp.MatchString(keyID) {
+			return "", nil, fmt.Errorf("invalid trust pin %q: %q is not a key ID", val, keyID)
+		}
+		keyIDs = append(keyIDs, keyID)
+	}
+	return name, keyIDs, nil
+}
+
+func convertTarget(t client.Target) (Target, error) {
+	h, ok := t.Hashes["sha256"]
+	if !ok {
+		return Target{}, fmt.Errorf("no valid hash, expecting sha256")
+	}
+	return Target{
+		Tag:    t.Name,
+		Digest: digest.NewDigestFromHex("sha256", hex.EncodeToString(h)),
+	}, nil
+}
+
+func notaryError(name string, err error) error {
+	if _, ok := err.(client.ErrRepositoryNotExist); ok {
+		return fmt.Errorf("no trust data for %s", name)
+	}
+	return fmt.Errorf("could not verify the trust data of %s: %v", name, err)
+}
+
+type credentialStore struct {
+	auth types.AuthConfig
+}
+
+func (cs credentialStore) Basic(*url.URL) (string, string) {
+	return cs.auth.Username, cs.auth.Password
+}
+
+func (cs credentialStore) RefreshToken(*url.URL, string) string {
+	return cs.auth.IdentityToken
+}
+
+func (cs credentialStore) SetRefreshToken(*url.URL, string, string) {
+}
@@ -0,0 +1,65 @@
+package trust
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var (
+	keyID1 = strings.Repeat("a", 64)
+	keyID2 = strings.Repeat("b", 64)
+)
+
+func TestParsePin(t *testing.T) {
+	name, keyIDs, err := ParsePin("busybox=" + keyID1 + ", " + keyID2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name != "docker.io/library/busybox" {
+		t.Fatalf("expected the name to be normalized, got %s", name)
+	}
+	if !reflect.DeepEqual(keyIDs, []string{keyID1, keyID2}) {
+		t.Fatalf("unexpected key IDs %v", keyIDs)
+	}
+
+	name, _, err = ParsePin("registry.example.com/*=" + keyID1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name != "registry.example.com/*" {
+		t.Fatalf("expected the prefix to be kept, got %s", name)
+	}
+
+	for _, val := range []string{
+		"",
+		"busybox",
+		"busybox=",
+		"=" + keyID1,
+		"busybox=notakeyid",
+		"busybox=" + keyID1 + ",",
+		"Busybox=" + keyID1,
+	} {
+		if _, _, err := ParsePin(val); err == nil {
+			t.Errorf("%q: expected an error", val)
+		}
+	}
+}
+
+func TestPins(t *testing.T) {
+	v := NewVerifier(Config{Pins: map[string][]string{
+		"docker.io/library/busybox": {keyID1},
+		"docker.io/*":               {keyID2},
+		"docker.io/library/*":       {keyID1, keyID2},
+	}})
+	for name, expected := range map[string][]string{
+		"docker.io/library/busybox": {keyID1},
+		"docker.io/library/ubuntu":  {keyID1, keyID2},
+		"docker.io/user/app":        {keyID2},
+		"registry.example.com/app":  nil,
+	} {
+		if pins := v.pins(name); !reflect.DeepEqual(pins, expected) {
+			t.Errorf("%s: expected %v, got %v", name, expected, pins)
+		}
+	}
+}
@@ -22,17 +22,36 @@ const maxDownloadAttempts = 5
 // layers.
 type LayerDownloadManager struct {
 	layerStore layer.Store
-	tm         TransferManager
+	scheduler  *registryScheduler
 }
 
-// NewLayerDownloadManager returns a new LayerDownloadManager.
-func NewLayerDownloadManager(layerStore layer.Store, concurrencyLimit int) *LayerDownloadManager {
+// NewLayerDownloadManager returns a new LayerDownloadManager. At most
+// concurrencyLimit layers are downloaded at a time, apart from the
+// registries of registryLimits which have their own limits.
+func NewLayerDownloadManager(layerStore layer.Store, concurrencyLimit int, registryLimits map[string]RegistryLimits) *LayerDownloadManager {
 	return &LayerDownloadManager{
 		layerStore: layerStore,
-		tm:         NewTransferManager(concurrencyLimit),
+		scheduler: newRegistryScheduler(concurrencyLimit, registryLimits, func(limits RegistryLimits) int {
+			return limits.MaxConcurrentDownloads
+		}),
 	}
 }
 
+// RateLimiter returns the limiter of the bandwidth of the downloads from a
+// registry, or nil if it is unlimited.
+func (ldm *LayerDownloadManager) RateLimiter(registry string) *RateLimiter {
+	return ldm.scheduler.rateLimiter(registry)
+}
+
+// transferManager returns the TransferManager scheduling the download of a
+// layer.
+func (ldm *LayerDownloadManager) transferManager(descriptor DownloadDescriptor) TransferManager {
+	if withRegistry, ok := descriptor.(DownloadDescriptorWithRegistry); ok {
+		return ldm.scheduler.transferManager(withRegistry.Registry())
+	}
+	return ldm.scheduler.defaultManager
+}
+
 type downloadTransfer struct {
 	Transfer
 
@@ -125,7 +144,7 @@ func (ldm *LayerDownloadManager) Download(ctx context.Context, initialRootFS ima
 		if existingDownload, ok := downloadsByKey[key]; ok {
 			xferFunc := ldm.makeDownloadFuncFromDownload(descriptor, existingDownload, topDownload)
 			defer topDownload.Transfer.Release(watcher)
-			topDownloadUncasted, watcher = ldm.tm.Transfer(transferKey, xferFunc, progressOutput)
+			topDownloadUncasted, watcher = ldm.transferManager(descriptor).Transfer(transferKey, xferFunc, progressOutput)
 			topDownload = topDownloadUncasted.(*downloadTransfer)
 			continue
 		}
@@ -140,7 +159,7 @@ func (ldm *LayerDownloadManager) Download(ctx context.Context, initialRootFS ima
 		} else {
 			xferFunc = ldm.makeDownloadFunc(descriptor, rootFS.ChainID(), nil)
 		}
-		topDownloadUncasted, watcher = ldm.tm.Transfer(transferKey, xferFunc, progressOutput)
+		topDownloadUncasted, watcher = ldm.transferManager(descriptor).Transfer(transferKey, xferFunc, progressOutput)
 		topDownload = topDownloadUncasted.(*downloadTransfer)
 		downloadsByKey[key] = topDownload
 	}
@@ -245,7 +245,7 @@ func TestSuccessfulDownload(t *testing.T) {
 		t.Skip("Needs fixing on Windows")
 	}
 	layerStore := &mockLayerStore{make(map[layer.ChainID]*mockLayer)}
-	ldm := NewLayerDownloadManager(layerStore, maxDownloadConcurrency)
+	ldm := NewLayerDownloadManager(layerStore, maxDownloadConcurrency, nil)
 
 	progressChan := make(chan progress.Progress)
 	progressDone := make(chan struct{})
@@ -306,7 +306,7 @@ func TestSuccessfulDownload(t *testing.T) {
 }
 
 func TestCancelledDownload(t *testing.T) {
-	ldm := NewLayerDownloadManager(&mockLayerStore{make(map[layer.ChainID]*mockLayer)}, maxDownloadConcurrency)
+	ldm := NewLayerDownloadManager(&mockLayerStore{make(map[layer.ChainID]*mockLayer)}, maxDownloadConcurrency, nil)
 
 	progressChan := make(chan progress.Progress)
 	progressDone := make(chan struct{})
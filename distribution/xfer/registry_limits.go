@@ -0,0 +1,201 @@
+package xfer
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/go-units"
+	"golang.org/x/net/context"
+)
+
+// RegistryLimits are the limits on the transfers of layers from and to a
+// registry.
+type RegistryLimits struct {
+	// MaxConcurrentDownloads and MaxConcurrentUploads are the maximum
+	// numbers of layers downloaded from and uploaded to the registry at a
+	// time. Zero keeps the limit of the manager.
+	MaxConcurrentDownloads int
+	MaxConcurrentUploads   int
+	// MaxBandwidth is the maximum number of bytes per second downloaded
+	// from the registry, and uploaded to it. Zero means unlimited.
+	MaxBandwidth int64
+}
+
+// ParseRegistryLimits parses limits written as comma separated key=value
+// pairs, like "max-concurrent-downloads=1,max-bandwidth=10MB".
+func ParseRegistryLimits(val string) (RegistryLimits, error) {
+	var limits RegistryLimits
+	for _, field := range strings.Split(val, ",") {
+		parts := strings.SplitN(strings.TrimSpace(field), "=", 2)
+		if len(parts) != 2 {
+			return limits, fmt.Errorf("invalid registry limit %q, expected key=value", field)
+		}
+		key, value := parts[0], parts[1]
+		var err error
+		switch key {
+		case "max-concurrent-downloads":
+			limits.MaxConcurrentDownloads, err = parseConcurrency(value)
+		case "max-concurrent-uploads":
+			limits.MaxConcurrentUploads, err = parseConcurrency(value)
+		case "max-bandwidth":
+			limits.MaxBandwidth, err = units.RAMInBytes(value)
+			if err == nil && limits.MaxBandwidth < 0 {
+				err = fmt.Errorf("negative bandwidth %s", value)
+			}
+		default:
+			return limits, fmt.Errorf("unknown registry limit %q", key)
+		}
+		if err != nil {
+			return limits, fmt.Errorf("invalid registry limit %s: %v", key, err)
+		}
+	}
+	return limits, nil
+}
+
+func parseConcurrency(value string) (int, error) {
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, err
+	}
+	if n < 1 {
+		return 0, fmt.Errorf("%d is lower than 1", n)
+	}
+	return n, nil
+}
+
+// DownloadDescriptorWithRegistry is a DownloadDescriptor that has an
+// additional Registry method returning the registry the layer is downloaded
+// from. The downloads from the registries with limits are scheduled apart
+// from the others.
+type DownloadDescriptorWithRegistry interface {
+	DownloadDescriptor
+	Registry() string
+}
+
+// UploadDescriptorWithRegistry is an UploadDescriptor that has an additional
+// Registry method returning the registry the layer is uploaded to. The
+// uploads to the registries with limits are scheduled apart from the others.
+type UploadDescriptorWithRegistry interface {
+	UploadDescriptor
+	Registry() string
+}
+
+// registryScheduler holds a TransferManager for each registry with limits,
+// so that the transfers of a slow registry do not delay the transfers of the
+// other registries, which share the default TransferManager.
+type registryScheduler struct {
+	defaultManager TransferManager
+	managers       map[string]TransferManager
+	rateLimiters   map[string]*RateLimiter
+}
+
+// newRegistryScheduler returns a registryScheduler. concurrency returns the
+// concurrency limit of a registry, or zero to use concurrencyLimit.
+func newRegistryScheduler(concurrencyLimit int, registryLimits map[string]RegistryLimits, concurrency func(RegistryLimits) int) *registryScheduler {
+	s := &registryScheduler{
+		defaultManager: NewTransferManager(concurrencyLimit),
+		managers:       make(map[string]TransferManager),
+		rateLimiters:   make(map[string]*RateLimiter),
+	}
+	for registry, limits := range registryLimits {
+		limit := concurrency(limits)
+		if limit == 0 {
+			limit = concurrencyLimit
+		}
+		s.managers[registry] = NewTransferManager(limit)
+		if limits.MaxBandwidth > 0 {
+			s.rateLimiters[registry] = NewRateLimiter(limits.MaxBandwidth)
+		}
+	}
+	return s
+}
+
+// transferManager returns the TransferManager of a registry.
+func (s *registryScheduler) transferManager(registry string) TransferManager {
+	if tm, ok := s.managers[registry]; ok {
+		return tm
+	}
+	return s.defaultManager
+}
+
+// rateLimiter returns the RateLimiter of a registry, or nil if its bandwidth
+// is unlimited.
+func (s *registryScheduler) rateLimiter(registry string) *RateLimiter {
+	return s.rateLimiters[registry]
+}
+
+// rateLimiterChunkSize is the largest number of bytes read at once from a
+// rate limited reader, so that the transfers sharing a limit progress
+// evenly.
+const rateLimiterChunkSize = 32 * 1024
+
+// RateLimiter caps the bandwidth shared by the readers it limits.
+type RateLimiter struct {
+	mu sync.Mutex
+	// rate is the number of bytes per second.
+	rate int64
+	// next is when the bytes read so far are within the rate.
+	next time.Time
+}
+
+// NewRateLimiter returns a RateLimiter of rate bytes per second.
+func NewRateLimiter(rate int64) *RateLimiter {
+	return &RateLimiter{rate: rate}
+}
+
+// wait waits until n more bytes can be read without exceeding the rate, or
+// until ctx is cancelled.
+func (l *RateLimiter) wait(ctx context.Context, n int) error {
+	l.mu.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	l.next = l.next.Add(time.Duration(int64(n) * int64(time.Second) / l.rate))
+	delay := l.next.Sub(now)
+	l.mu.Unlock()
+
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Limit returns a reader reading from rc within the rate of the limiter. A
+// nil RateLimiter returns rc.
+func (l *RateLimiter) Limit(ctx context.Context, rc io.ReadCloser) io.ReadCloser {
+	if l == nil {
+		return rc
+	}
+	return &rateLimitedReader{ReadCloser: rc, ctx: ctx, limiter: l}
+}
+
+type rateLimitedReader struct {
+	io.ReadCloser
+	ctx     context.Context
+	limiter *RateLimiter
+}
+
+func (r *rateLimitedReader) Read(p []byte) (int, error) {
+	if len(p) > rateLimiterChunkSize {
+		p = p[:rateLimiterChunkSize]
+	}
+	n, err := r.ReadCloser.Read(p)
+	if n > 0 {
+		if waitErr := r.limiter.wait(r.ctx, n); waitErr != nil {
+			return n, waitErr
+		}
+	}
+	return n, err
+}
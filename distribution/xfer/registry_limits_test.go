@@ -0,0 +1,151 @@
+package xfer
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/pkg/progress"
+	"golang.org/x/net/context"
+)
+
+func TestParseRegistryLimits(t *testing.T) {
+	valid := map[string]RegistryLimits{
+		"max-concurrent-downloads=1":                          {MaxConcurrentDownloads: 1},
+		"max-concurrent-uploads=2, max-bandwidth=1k":          {MaxConcurrentUploads: 2, MaxBandwidth: 1024},
+		"max-concurrent-downloads=4,max-concurrent-uploads=1": {MaxConcurrentDownloads: 4, MaxConcurrentUploads: 1},
+		"max-bandwidth=10MB":                                  {MaxBandwidth: 10 << 20},
+		"max-concurrent-downloads=10,max-bandwidth=0":         {MaxConcurrentDownloads: 10},
+	}
+	for val, expected := range valid {
+		limits, err := ParseRegistryLimits(val)
+		if err != nil {
+			t.Errorf("%q: %v", val, err)
+			continue
+		}
+		if limits != expected {
+			t.Errorf("%q: expected %+v, got %+v", val, expected, limits)
+		}
+	}
+
+	for _, val := range []string{
+		"",
+		"max-concurrent-downloads",
+		"max-concurrent-downloads=0",
+		"max-concurrent-uploads=-1",
+		"max-concurrent-uploads=many",
+		"max-bandwidth=fast",
+		"max-bandwidth=-1",
+		"max-downloads=1",
+	} {
+		if _, err := ParseRegistryLimits(val); err == nil {
+			t.Errorf("%q: expected an error", val)
+		}
+	}
+}
+
+func TestRegistryScheduler(t *testing.T) {
+	s := newRegistryScheduler(2, map[string]RegistryLimits{
+		"slow.example.com":  {MaxConcurrentDownloads: 1, MaxBandwidth: 1024},
+		"other.example.com": {MaxConcurrentUploads: 1},
+	}, func(limits RegistryLimits) int {
+		return limits.MaxConcurrentDownloads
+	})
+
+	slow := s.transferManager("slow.example.com")
+	if slow == s.defaultManager {
+		t.Fatal("expected a registry with limits to have its own transfer manager")
+	}
+	if s.transferManager("docker.io") != s.defaultManager {
+		t.Fatal("expected a registry without limits to use the default transfer manager")
+	}
+	if s.rateLimiter("slow.example.com") == nil {
+		t.Fatal("expected a rate limiter for a registry with a bandwidth limit")
+	}
+	if s.rateLimiter("other.example.com") != nil || s.rateLimiter("docker.io") != nil {
+		t.Fatal("expected no rate limiter for the registries without a bandwidth limit")
+	}
+
+	// Block the transfer manager of the slow registry, and check that the
+	// transfers of the other registries still start.
+	blockedXferFunc := func(started chan<- struct{}) DoFunc {
+		return func(progressChan chan<- progress.Progress, start <-chan struct{}, inactive chan<- struct{}) Transfer {
+			xfer := NewTransfer()
+			go func() {
+				<-start
+				close(started)
+				<-xfer.Context().Done()
+				close(progressChan)
+			}()
+			return xfer
+		}
+	}
+	progressChan := make(chan progress.Progress)
+	go func() {
+		for range progressChan {
+		}
+	}()
+	defer close(progressChan)
+
+	first := make(chan struct{})
+	second := make(chan struct{})
+	other := make(chan struct{})
+	x1, w1 := slow.Transfer("slow1", blockedXferFunc(first), progress.ChanOutput(progressChan))
+	x2, w2 := slow.Transfer("slow2", blockedXferFunc(second), progress.ChanOutput(progressChan))
+	x3, w3 := s.transferManager("docker.io").Transfer("other", blockedXferFunc(other), progress.ChanOutput(progressChan))
+
+	for _, started := range []chan struct{}{first, other} {
+		select {
+		case <-started:
+		case <-time.After(5 * time.Second):
+			t.Fatal("transfer not started")
+		}
+	}
+	select {
+	case <-second:
+		t.Fatal("transfer started beyond the concurrency limit of the registry")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	x1.Release(w1)
+	select {
+	case <-second:
+	case <-time.After(5 * time.Second):
+		t.Fatal("transfer not started once another one finished")
+	}
+	x2.Release(w2)
+	x3.Release(w3)
+	for _, x := range []Transfer{x1, x2, x3} {
+		<-x.Done()
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	var nilLimiter *RateLimiter
+	rc := ioutil.NopCloser(bytes.NewReader(nil))
+	if nilLimiter.Limit(context.Background(), rc) != rc {
+		t.Fatal("expected a nil rate limiter not to wrap readers")
+	}
+
+	data := make([]byte, 64*1024)
+	limiter := NewRateLimiter(256 * 1024)
+	begin := time.Now()
+	read, err := ioutil.ReadAll(limiter.Limit(context.Background(), ioutil.NopCloser(bytes.NewReader(data))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(read) != len(data) {
+		t.Fatalf("expected %d bytes, got %d", len(data), len(read))
+	}
+	if elapsed := time.Since(begin); elapsed < 200*time.Millisecond {
+		t.Fatalf("read 64KB in %v at 256KB/s", elapsed)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	limiter = NewRateLimiter(1)
+	if _, err := ioutil.ReadAll(limiter.Limit(ctx, ioutil.NopCloser(bytes.NewReader(data)))); err != context.Canceled {
+		t.Fatalf("expected the read to be cancelled, got %v", err)
+	}
+}
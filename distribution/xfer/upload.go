@@ -16,16 +16,35 @@ const maxUploadAttempts = 5
 // LayerUploadManager provides task management and progress reporting for
 // uploads.
 type LayerUploadManager struct {
-	tm TransferManager
+	scheduler *registryScheduler
 }
 
-// NewLayerUploadManager returns a new LayerUploadManager.
-func NewLayerUploadManager(concurrencyLimit int) *LayerUploadManager {
+// NewLayerUploadManager returns a new LayerUploadManager. At most
+// concurrencyLimit layers are uploaded at a time, apart from the registries
+// of registryLimits which have their own limits.
+func NewLayerUploadManager(concurrencyLimit int, registryLimits map[string]RegistryLimits) *LayerUploadManager {
 	return &LayerUploadManager{
-		tm: NewTransferManager(concurrencyLimit),
+		scheduler: newRegistryScheduler(concurrencyLimit, registryLimits, func(limits RegistryLimits) int {
+			return limits.MaxConcurrentUploads
+		}),
 	}
 }
 
+// RateLimiter returns the limiter of the bandwidth of the uploads to a
+// registry, or nil if it is unlimited.
+func (lum *LayerUploadManager) RateLimiter(registry string) *RateLimiter {
+	return lum.scheduler.rateLimiter(registry)
+}
+
+// transferManager returns the TransferManager scheduling the upload of a
+// layer.
+func (lum *LayerUploadManager) transferManager(descriptor UploadDescriptor) TransferManager {
+	if withRegistry, ok := descriptor.(UploadDescriptorWithRegistry); ok {
+		return lum.scheduler.transferManager(withRegistry.Registry())
+	}
+	return lum.scheduler.defaultManager
+}
+
 type uploadTransfer struct {
 	Transfer
 
@@ -68,7 +87,7 @@ func (lum *LayerUploadManager) Upload(ctx context.Context, layers []UploadDescri
 		}
 
 		xferFunc := lum.makeUploadFunc(descriptor)
-		upload, watcher := lum.tm.Transfer(descriptor.Key(), xferFunc, progressOutput)
+		upload, watcher := lum.transferManager(descriptor).Transfer(descriptor.Key(), xferFunc, progressOutput)
 		defer upload.Release(watcher)
 		uploads = append(uploads, upload.(*uploadTransfer))
 		dedupDescriptors[key] = upload.(*uploadTransfer)
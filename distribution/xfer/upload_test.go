@@ -88,7 +88,7 @@ var expectedDigests = map[layer.DiffID]digest.Digest{
 }
 
 func TestSuccessfulUpload(t *testing.T) {
-	lum := NewLayerUploadManager(maxUploadConcurrency)
+	lum := NewLayerUploadManager(maxUploadConcurrency, nil)
 
 	progressChan := make(chan progress.Progress)
 	progressDone := make(chan struct{})
@@ -114,7 +114,7 @@ func TestSuccessfulUpload(t *testing.T) {
 }
 
 func TestCancelledUpload(t *testing.T) {
-	lum := NewLayerUploadManager(maxUploadConcurrency)
+	lum := NewLayerUploadManager(maxUploadConcurrency, nil)
 
 	progressChan := make(chan progress.Progress)
 	progressDone := make(chan struct{})
@@ -15,6 +15,7 @@ import (
 	"github.com/Sirupsen/logrus"
 	"github.com/docker/distribution/uuid"
 	apiserver "github.com/docker/docker/api/server"
+	"github.com/docker/docker/api/server/middleware"
 	"github.com/docker/docker/api/server/router"
 	"github.com/docker/docker/api/server/router/build"
 	"github.com/docker/docker/api/server/router/container"
@@ -27,6 +28,7 @@ import (
 	"github.com/docker/docker/cliconfig"
 	"github.com/docker/docker/daemon"
 	"github.com/docker/docker/daemon/logger"
+	"github.com/docker/docker/distribution/proxy"
 	"github.com/docker/docker/docker/listeners"
 	"github.com/docker/docker/dockerversion"
 	"github.com/docker/docker/opts"
@@ -66,6 +68,8 @@ func NewDaemonCli() *DaemonCli {
 	daemonConfig := new(daemon.Config)
 	daemonConfig.LogConfig.Config = make(map[string]string)
 	daemonConfig.ClusterOpts = make(map[string]string)
+	daemonConfig.RegistryTransferLimits = make(map[string]string)
+	daemonConfig.ContentTrustPins = make(map[string]string)
 
 	if runtime.GOOS != "linux" {
 		daemonConfig.V2Only = true
@@ -211,6 +215,7 @@ func (cli *DaemonCli) CmdDaemon(args ...string) error {
 		Logging:                  true,
 		SocketGroup:              cli.Config.SocketGroup,
 		Version:                  dockerversion.Version,
+		EnableHTTP2:              cli.Config.APIHTTP2,
 	}
 	serverConfig = setPlatformServerConfig(serverConfig, cli.Config)
 
@@ -229,9 +234,21 @@ func (cli *DaemonCli) CmdDaemon(args ...string) error {
 		if err != nil {
 			logrus.Fatal(err)
 		}
+		if cli.Config.APIHTTP2 {
+			// Let clients negotiate HTTP/2 through ALPN.
+			tlsConfig.NextProtos = []string{"h2", "http/1.1"}
+		}
 		serverConfig.TLSConfig = tlsConfig
 	}
 
+	if cli.Config.APIHMACKeys != "" || cli.Config.APIAuthorizedKeys != "" {
+		keys, err := middleware.LoadSignatureKeys(cli.Config.APIHMACKeys, cli.Config.APIAuthorizedKeys)
+		if err != nil {
+			logrus.Fatal(err)
+		}
+		serverConfig.SignatureKeys = keys
+	}
+
 	if len(cli.Config.Hosts) == 0 {
 		cli.Config.Hosts = make([]string, 1)
 	}
@@ -264,6 +281,13 @@ func (cli *DaemonCli) CmdDaemon(args ...string) error {
 	cli.TrustKeyPath = commonFlags.TrustKey
 
 	registryService := registry.NewService(cli.Config.ServiceOptions)
+	stopMirrorChecks := registryService.StartMirrorHealthChecks()
+	defer stopMirrorChecks()
+	stopCertsWatcher, err := registry.StartCertsWatcher()
+	if err != nil {
+		logrus.Warnf("Could not watch the registry certificates, they will be read for every connection: %v", err)
+	}
+	defer stopCertsWatcher()
 	d, err := daemon.NewDaemon(cli.Config, registryService)
 	if err != nil {
 		if pfile != nil {
@@ -274,6 +298,14 @@ func (cli *DaemonCli) CmdDaemon(args ...string) error {
 		logrus.Fatalf("Error starting daemon: %v", err)
 	}
 
+	if cli.Config.RegistryCache {
+		stopRegistryCache, err := startRegistryCache(cli.Config, registryService)
+		if err != nil {
+			logrus.Fatalf("Error starting the registry cache: %v", err)
+		}
+		defer stopRegistryCache()
+	}
+
 	logrus.Info("Daemon has completed initialization")
 
 	logrus.WithFields(logrus.Fields{
@@ -398,6 +430,21 @@ func loadDaemonCliConfig(config *daemon.Config, daemonFlags *flag.FlagSet, commo
 	return config, nil
 }
 
+// startRegistryCache serves the pull-through cache of Docker Hub, keeping
+// its data under the root of the daemon.
+func startRegistryCache(config *daemon.Config, registryService *registry.Service) (stop func(), err error) {
+	cache, err := proxy.New(filepath.Join(config.Root, "registry-cache"), registryService)
+	if err != nil {
+		return nil, err
+	}
+	stop, err = cache.Serve(config.RegistryCacheAddr)
+	if err != nil {
+		return nil, err
+	}
+	logrus.Infof("Registry cache listening on %s", config.RegistryCacheAddr)
+	return stop, nil
+}
+
 func initRouter(s *apiserver.Server, d *daemon.Daemon) {
 	routers := []router.Router{
 		container.NewRouter(d),
@@ -4,7 +4,9 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"os"
 	"sync"
+	"time"
 
 	"github.com/Sirupsen/logrus"
 	"github.com/docker/distribution/digest"
@@ -19,6 +21,10 @@ type Store interface {
 	Search(partialID string) (ID, error)
 	SetParent(id ID, parent ID) error
 	GetParent(id ID) (ID, error)
+	SetLastUsed(id ID, t time.Time) error
+	GetLastUsed(id ID) (time.Time, error)
+	SetAnnotations(id ID, annotations map[string]string) error
+	GetAnnotations(id ID) (map[string]string, error)
 	Children(id ID) []ID
 	Map() map[ID]*Image
 	Heads() map[ID]*Image
@@ -249,6 +255,47 @@ func (is *store) GetParent(id ID) (ID, error) {
 	return ID(d), nil // todo: validate?
 }
 
+// SetLastUsed records the last time a container was created from an image.
+func (is *store) SetLastUsed(id ID, t time.Time) error {
+	return is.fs.SetMetadata(id, "lastUsed", []byte(t.UTC().Format(time.RFC3339Nano)))
+}
+
+// GetLastUsed returns the last time a container was created from an image.
+func (is *store) GetLastUsed(id ID) (time.Time, error) {
+	d, err := is.fs.GetMetadata(id, "lastUsed")
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Parse(time.RFC3339Nano, string(d))
+}
+
+// SetAnnotations records the annotations of an image that are not part of
+// its config, such as the annotations of an OCI image manifest.
+func (is *store) SetAnnotations(id ID, annotations map[string]string) error {
+	d, err := json.Marshal(annotations)
+	if err != nil {
+		return err
+	}
+	return is.fs.SetMetadata(id, "annotations", d)
+}
+
+// GetAnnotations returns the annotations recorded for an image, or nil if
+// the image has none.
+func (is *store) GetAnnotations(id ID) (map[string]string, error) {
+	d, err := is.fs.GetMetadata(id, "annotations")
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var annotations map[string]string
+	if err := json.Unmarshal(d, &annotations); err != nil {
+		return nil, err
+	}
+	return annotations, nil
+}
+
 func (is *store) Children(id ID) []ID {
 	is.Lock()
 	defer is.Unlock()
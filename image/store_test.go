@@ -4,6 +4,7 @@ import (
 	"io/ioutil"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/docker/distribution/digest"
 	"github.com/docker/docker/layer"
@@ -289,6 +290,82 @@ func TestParentReset(t *testing.T) {
 
 }
 
+func TestLastUsed(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "images-fs-store")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpdir)
+	fs, err := NewFSStoreBackend(tmpdir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	is, err := NewImageStore(fs, &mockLayerGetReleaser{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id, err := is.Create([]byte(`{"comment": "abc1", "rootfs": {"type": "layers"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := is.GetLastUsed(id); err == nil {
+		t.Fatal("expected an error for an image never used")
+	}
+
+	used := time.Date(2016, 4, 1, 12, 0, 0, 0, time.UTC)
+	if err := is.SetLastUsed(id, used); err != nil {
+		t.Fatal(err)
+	}
+	lastUsed, err := is.GetLastUsed(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !lastUsed.Equal(used) {
+		t.Fatalf("invalid last use: expected %s, got %s", used, lastUsed)
+	}
+}
+
+func TestAnnotations(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "images-fs-store")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpdir)
+	fs, err := NewFSStoreBackend(tmpdir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	is, err := NewImageStore(fs, &mockLayerGetReleaser{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id, err := is.Create([]byte(`{"comment": "abc1", "rootfs": {"type": "layers"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	annotations, err := is.GetAnnotations(id)
+	if err != nil || annotations != nil {
+		t.Fatalf("expected no annotations, got %v, %v", annotations, err)
+	}
+
+	if err := is.SetAnnotations(id, map[string]string{"org.opencontainers.image.version": "1.0"}); err != nil {
+		t.Fatal(err)
+	}
+	annotations, err = is.GetAnnotations(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(annotations) != 1 || annotations["org.opencontainers.image.version"] != "1.0" {
+		t.Fatalf("invalid annotations %v", annotations)
+	}
+}
+
 type mockLayerGetReleaser struct{}
 
 func (ls *mockLayerGetReleaser) Get(layer.ChainID) (layer.Layer, error) {
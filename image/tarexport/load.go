@@ -39,6 +39,14 @@ func (l *tarexporter) Load(inTar io.ReadCloser, outStream io.Writer, quiet bool)
 	if err := chrootarchive.Untar(inTar, tmpDir, nil); err != nil {
 		return err
 	}
+	// an OCI image layout is detected by its layout file
+	layoutPath, err := safePath(tmpDir, ociLayoutFileName)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(layoutPath); err == nil {
+		return l.ociLoad(tmpDir, outStream, progressOutput)
+	}
 	// read manifest, if no file then load in legacy mode
 	manifestPath, err := safePath(tmpDir, manifestFileName)
 	if err != nil {
@@ -84,19 +92,11 @@ func (l *tarexporter) Load(inTar io.ReadCloser, outStream io.Writer, quiet bool)
 			if err != nil {
 				return err
 			}
-			r := rootFS
-			r.Append(diffID)
-			newLayer, err := l.ls.Get(r.ChainID())
+			newLayer, err := l.loadImageLayer(layerPath, rootFS, diffID, i, progressOutput)
 			if err != nil {
-				newLayer, err = l.loadLayer(layerPath, rootFS, diffID.String(), progressOutput)
-				if err != nil {
-					return err
-				}
+				return err
 			}
 			defer layer.ReleaseAndLog(l.ls, newLayer)
-			if expected, actual := diffID, newLayer.DiffID(); expected != actual {
-				return fmt.Errorf("invalid diffID for layer %d: expected %q, got %q", i, expected, actual)
-			}
 			rootFS.Append(diffID)
 		}
 
@@ -122,6 +122,25 @@ func (l *tarexporter) Load(inTar io.ReadCloser, outStream io.Writer, quiet bool)
 	return nil
 }
 
+// loadImageLayer returns the layer with diffID on top of rootFS, and loads
+// it from filename if it is not in the layer store yet.
+func (l *tarexporter) loadImageLayer(filename string, rootFS image.RootFS, diffID layer.DiffID, i int, progressOutput progress.Output) (layer.Layer, error) {
+	r := rootFS
+	r.Append(diffID)
+	newLayer, err := l.ls.Get(r.ChainID())
+	if err != nil {
+		newLayer, err = l.loadLayer(filename, rootFS, diffID.String(), progressOutput)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if expected, actual := diffID, newLayer.DiffID(); expected != actual {
+		layer.ReleaseAndLog(l.ls, newLayer)
+		return nil, fmt.Errorf("invalid diffID for layer %d: expected %q, got %q", i, expected, actual)
+	}
+	return newLayer, nil
+}
+
 func (l *tarexporter) loadLayer(filename string, rootFS image.RootFS, id string, progressOutput progress.Output) (layer.Layer, error) {
 	rawTar, err := os.Open(filename)
 	if err != nil {
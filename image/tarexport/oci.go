@@ -0,0 +1,407 @@
+package tarexport
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/docker/distribution/digest"
+	"github.com/docker/docker/image"
+	"github.com/docker/docker/layer"
+	"github.com/docker/docker/pkg/archive"
+	"github.com/docker/docker/pkg/progress"
+	"github.com/docker/docker/pkg/system"
+	"github.com/docker/docker/reference"
+)
+
+const (
+	ociLayoutFileName = "oci-layout"
+	ociIndexFileName  = "index.json"
+	ociBlobsDir       = "blobs"
+	ociLayoutVersion  = "1.0.0"
+
+	ociMediaTypeManifest  = "application/vnd.oci.image.manifest.v1+json"
+	ociMediaTypeConfig    = "application/vnd.oci.image.config.v1+json"
+	ociMediaTypeLayer     = "application/vnd.oci.image.layer.v1.tar"
+	ociMediaTypeLayerGzip = "application/vnd.oci.image.layer.v1.tar+gzip"
+
+	// ociRefNameAnnotation is the annotation of the index of an OCI image
+	// layout naming an image, usually with its tag only.
+	ociRefNameAnnotation = "org.opencontainers.image.ref.name"
+	// ociImageNameAnnotation is the annotation used by containerd to
+	// record the full reference of an image.
+	ociImageNameAnnotation = "io.containerd.image.name"
+)
+
+type ociLayout struct {
+	ImageLayoutVersion string `json:"imageLayoutVersion"`
+}
+
+type ociDescriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      digest.Digest     `json:"digest"`
+	Size        int64             `json:"size"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+type ociIndex struct {
+	SchemaVersion int             `json:"schemaVersion"`
+	Manifests     []ociDescriptor `json:"manifests"`
+}
+
+type ociManifest struct {
+	SchemaVersion int               `json:"schemaVersion"`
+	MediaType     string            `json:"mediaType,omitempty"`
+	Config        ociDescriptor     `json:"config"`
+	Layers        []ociDescriptor   `json:"layers"`
+	Annotations   map[string]string `json:"annotations,omitempty"`
+}
+
+// SaveOCI writes the images to outStream as an OCI image layout. The config
+// and the layers of the images are stored as blobs named by their digests,
+// so the image IDs and the layer diff IDs are preserved.
+func (l *tarexporter) SaveOCI(names []string, outStream io.Writer) error {
+	images, err := l.parseNames(names)
+	if err != nil {
+		return err
+	}
+
+	return (&saveSession{tarexporter: l, images: images}).saveOCI(outStream)
+}
+
+func (s *saveSession) saveOCI(outStream io.Writer) error {
+	s.savedLayers = make(map[string]struct{})
+
+	tempDir, err := ioutil.TempDir("", "docker-export-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tempDir)
+
+	s.outDir = tempDir
+	if err := os.MkdirAll(filepath.Join(tempDir, ociBlobsDir, string(digest.Canonical)), 0755); err != nil {
+		return err
+	}
+
+	index := ociIndex{SchemaVersion: 2, Manifests: []ociDescriptor{}}
+	for id, imageDescr := range s.images {
+		desc, err := s.saveOCIImage(id)
+		if err != nil {
+			return err
+		}
+		if len(imageDescr.refs) == 0 {
+			index.Manifests = append(index.Manifests, desc)
+			continue
+		}
+		// every reference of the image is a descriptor of its manifest
+		for _, ref := range imageDescr.refs {
+			d := desc
+			d.Annotations = map[string]string{
+				ociRefNameAnnotation:   ref.Tag(),
+				ociImageNameAnnotation: ref.FullName() + ":" + ref.Tag(),
+			}
+			index.Manifests = append(index.Manifests, d)
+		}
+	}
+
+	layout, err := json.Marshal(ociLayout{ImageLayoutVersion: ociLayoutVersion})
+	if err != nil {
+		return err
+	}
+	if err := writeOCIFile(filepath.Join(tempDir, ociLayoutFileName), layout); err != nil {
+		return err
+	}
+	indexJSON, err := json.Marshal(index)
+	if err != nil {
+		return err
+	}
+	if err := writeOCIFile(filepath.Join(tempDir, ociIndexFileName), indexJSON); err != nil {
+		return err
+	}
+
+	fs, err := archive.Tar(tempDir, archive.Uncompressed)
+	if err != nil {
+		return err
+	}
+	defer fs.Close()
+
+	_, err = io.Copy(outStream, fs)
+	return err
+}
+
+// saveOCIImage writes the config, the layers and the manifest of an image
+// as blobs, and returns the descriptor of the manifest.
+func (s *saveSession) saveOCIImage(id image.ID) (ociDescriptor, error) {
+	img, err := s.is.Get(id)
+	if err != nil {
+		return ociDescriptor{}, err
+	}
+	annotations, err := s.is.GetAnnotations(id)
+	if err != nil {
+		return ociDescriptor{}, err
+	}
+
+	config, err := s.writeOCIBlob(img.RawJSON(), ociMediaTypeConfig)
+	if err != nil {
+		return ociDescriptor{}, err
+	}
+	manifest := ociManifest{
+		SchemaVersion: 2,
+		MediaType:     ociMediaTypeManifest,
+		Config:        config,
+		Layers:        []ociDescriptor{},
+		Annotations:   annotations,
+	}
+	rootFS := *img.RootFS
+	for i := range img.RootFS.DiffIDs {
+		rootFS.DiffIDs = img.RootFS.DiffIDs[:i+1]
+		desc, err := s.saveOCILayer(rootFS.ChainID())
+		if err != nil {
+			return ociDescriptor{}, err
+		}
+		manifest.Layers = append(manifest.Layers, desc)
+	}
+
+	manifestJSON, err := json.Marshal(manifest)
+	if err != nil {
+		return ociDescriptor{}, err
+	}
+	return s.writeOCIBlob(manifestJSON, ociMediaTypeManifest)
+}
+
+// saveOCILayer writes the uncompressed tar stream of a layer as a blob. The
+// digest of the blob is the diff ID of the layer.
+func (s *saveSession) saveOCILayer(id layer.ChainID) (ociDescriptor, error) {
+	l, err := s.ls.Get(id)
+	if err != nil {
+		return ociDescriptor{}, err
+	}
+	defer layer.ReleaseAndLog(s.ls, l)
+
+	dgst := digest.Digest(l.DiffID())
+	blobPath := filepath.Join(s.outDir, ociBlobsDir, string(dgst.Algorithm()), dgst.Hex())
+	if _, saved := s.savedLayers[dgst.String()]; saved {
+		fi, err := os.Stat(blobPath)
+		if err != nil {
+			return ociDescriptor{}, err
+		}
+		return ociDescriptor{MediaType: ociMediaTypeLayer, Digest: dgst, Size: fi.Size()}, nil
+	}
+
+	arch, err := l.TarStream()
+	if err != nil {
+		return ociDescriptor{}, err
+	}
+	defer arch.Close()
+
+	blob, err := os.Create(blobPath)
+	if err != nil {
+		return ociDescriptor{}, err
+	}
+	defer blob.Close()
+	size, err := io.Copy(blob, arch)
+	if err != nil {
+		return ociDescriptor{}, err
+	}
+	if err := system.Chtimes(blobPath, time.Unix(0, 0), time.Unix(0, 0)); err != nil {
+		return ociDescriptor{}, err
+	}
+
+	s.savedLayers[dgst.String()] = struct{}{}
+	return ociDescriptor{MediaType: ociMediaTypeLayer, Digest: dgst, Size: size}, nil
+}
+
+// writeOCIBlob writes data as a blob named by its digest.
+func (s *saveSession) writeOCIBlob(data []byte, mediaType string) (ociDescriptor, error) {
+	dgst := digest.FromBytes(data)
+	blobPath := filepath.Join(s.outDir, ociBlobsDir, string(dgst.Algorithm()), dgst.Hex())
+	if err := writeOCIFile(blobPath, data); err != nil {
+		return ociDescriptor{}, err
+	}
+	return ociDescriptor{MediaType: mediaType, Digest: dgst, Size: int64(len(data))}, nil
+}
+
+func writeOCIFile(path string, data []byte) error {
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		return err
+	}
+	return system.Chtimes(path, time.Unix(0, 0), time.Unix(0, 0))
+}
+
+func (l *tarexporter) ociLoad(tmpDir string, outStream io.Writer, progressOutput progress.Output) error {
+	layoutPath, err := safePath(tmpDir, ociLayoutFileName)
+	if err != nil {
+		return err
+	}
+	layoutJSON, err := ioutil.ReadFile(layoutPath)
+	if err != nil {
+		return err
+	}
+	var layout ociLayout
+	if err := json.Unmarshal(layoutJSON, &layout); err != nil {
+		return err
+	}
+	if layout.ImageLayoutVersion != ociLayoutVersion {
+		return fmt.Errorf("unsupported OCI image layout version %q", layout.ImageLayoutVersion)
+	}
+
+	indexPath, err := safePath(tmpDir, ociIndexFileName)
+	if err != nil {
+		return err
+	}
+	indexJSON, err := ioutil.ReadFile(indexPath)
+	if err != nil {
+		return err
+	}
+	var index ociIndex
+	if err := json.Unmarshal(indexJSON, &index); err != nil {
+		return err
+	}
+
+	loaded := make(map[digest.Digest]image.ID)
+	for _, desc := range index.Manifests {
+		imgID, ok := loaded[desc.Digest]
+		if !ok {
+			if imgID, err = l.ociLoadImage(tmpDir, desc, progressOutput); err != nil {
+				return err
+			}
+			loaded[desc.Digest] = imgID
+		}
+
+		ref, err := ociReference(desc.Annotations)
+		if err != nil {
+			return err
+		}
+		if ref == nil {
+			fmt.Fprintf(outStream, "Loaded image ID: %s\n", imgID)
+			continue
+		}
+		if err := l.setLoadedTag(ref, imgID, outStream); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ociLoadImage loads the image of the manifest described by desc, and
+// returns its ID.
+func (l *tarexporter) ociLoadImage(tmpDir string, desc ociDescriptor, progressOutput progress.Output) (image.ID, error) {
+	if desc.MediaType != ociMediaTypeManifest {
+		return "", fmt.Errorf("unsupported OCI manifest media type %q", desc.MediaType)
+	}
+	manifestJSON, err := readOCIBlob(tmpDir, desc)
+	if err != nil {
+		return "", err
+	}
+	var manifest ociManifest
+	if err := json.Unmarshal(manifestJSON, &manifest); err != nil {
+		return "", err
+	}
+	config, err := readOCIBlob(tmpDir, manifest.Config)
+	if err != nil {
+		return "", err
+	}
+	img, err := image.NewFromJSON(config)
+	if err != nil {
+		return "", err
+	}
+
+	if expected, actual := len(manifest.Layers), len(img.RootFS.DiffIDs); expected != actual {
+		return "", fmt.Errorf("invalid manifest, layers length mismatch: expected %d, got %d", expected, actual)
+	}
+	rootFS := *img.RootFS
+	rootFS.DiffIDs = nil
+	for i, diffID := range img.RootFS.DiffIDs {
+		switch manifest.Layers[i].MediaType {
+		case ociMediaTypeLayer, ociMediaTypeLayerGzip:
+		default:
+			return "", fmt.Errorf("unsupported OCI layer media type %q", manifest.Layers[i].MediaType)
+		}
+		layerPath, err := ociBlobPath(tmpDir, manifest.Layers[i].Digest)
+		if err != nil {
+			return "", err
+		}
+		newLayer, err := l.loadImageLayer(layerPath, rootFS, diffID, i, progressOutput)
+		if err != nil {
+			return "", err
+		}
+		defer layer.ReleaseAndLog(l.ls, newLayer)
+		rootFS.Append(diffID)
+	}
+
+	imgID, err := l.is.Create(config)
+	if err != nil {
+		return "", err
+	}
+	if len(manifest.Annotations) > 0 {
+		if err := l.is.SetAnnotations(imgID, manifest.Annotations); err != nil {
+			return "", err
+		}
+	}
+	return imgID, nil
+}
+
+// ociReference returns the reference of an image from the annotations of
+// its descriptor in the index, or nil if they have no full reference.
+func ociReference(annotations map[string]string) (reference.NamedTagged, error) {
+	name, ok := annotations[ociImageNameAnnotation]
+	if !ok {
+		// a reference name without a repository is only a tag
+		name = annotations[ociRefNameAnnotation]
+		if !strings.ContainsAny(name, "/:") {
+			return nil, nil
+		}
+	}
+	named, err := reference.ParseNamed(name)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := named.(reference.Canonical); ok {
+		return nil, nil
+	}
+	named = reference.WithDefaultTag(named)
+	ref, ok := named.(reference.NamedTagged)
+	if !ok {
+		return nil, fmt.Errorf("invalid tag %q", name)
+	}
+	return ref, nil
+}
+
+func ociBlobPath(tmpDir string, dgst digest.Digest) (string, error) {
+	if err := dgst.Validate(); err != nil {
+		return "", err
+	}
+	return safePath(tmpDir, filepath.Join(ociBlobsDir, string(dgst.Algorithm()), dgst.Hex()))
+}
+
+// readOCIBlob reads the blob described by desc, and verifies its digest.
+func readOCIBlob(tmpDir string, desc ociDescriptor) ([]byte, error) {
+	blobPath, err := ociBlobPath(tmpDir, desc.Digest)
+	if err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadFile(blobPath)
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) != desc.Size {
+		return nil, fmt.Errorf("invalid OCI blob %s: expected %d bytes, got %d", desc.Digest, desc.Size, len(data))
+	}
+	verifier, err := digest.NewDigestVerifier(desc.Digest)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.Copy(verifier, bytes.NewReader(data)); err != nil {
+		return nil, err
+	}
+	if !verifier.Verified() {
+		return nil, fmt.Errorf("invalid OCI blob %s: content does not match its digest", desc.Digest)
+	}
+	return data, nil
+}
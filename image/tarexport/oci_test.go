@@ -0,0 +1,194 @@
+package tarexport
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/docker/distribution/digest"
+	"github.com/docker/docker/daemon/graphdriver"
+	"github.com/docker/docker/daemon/graphdriver/vfs"
+	"github.com/docker/docker/image"
+	"github.com/docker/docker/layer"
+	"github.com/docker/docker/pkg/archive"
+	"github.com/docker/docker/pkg/idtools"
+	"github.com/docker/docker/reference"
+)
+
+func newTestExporter(t *testing.T, root string) *tarexporter {
+	idMaps := []idtools.IDMap{{ContainerID: 0, HostID: os.Getuid(), Size: 1}}
+	gidMaps := []idtools.IDMap{{ContainerID: 0, HostID: os.Getgid(), Size: 1}}
+	driver, err := graphdriver.GetDriver("vfs", filepath.Join(root, "graph"), nil, idMaps, gidMaps)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fms, err := layer.NewFSMetadataStore(filepath.Join(root, "layers"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ls, err := layer.NewStoreFromGraphDriver(fms, driver)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ifs, err := image.NewFSStoreBackend(filepath.Join(root, "images"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	is, err := image.NewImageStore(ifs, ls)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs, err := reference.NewReferenceStore(filepath.Join(root, "repositories.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &tarexporter{is: is, ls: ls, rs: rs}
+}
+
+func testLayerTar(t *testing.T, name string) io.Reader {
+	buf := &bytes.Buffer{}
+	tw := tar.NewWriter(buf)
+	if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(name)), Typeflag: tar.TypeReg}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tw.Write([]byte(name)); err != nil {
+		t.Fatal(err)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf
+}
+
+func TestSaveLoadOCI(t *testing.T) {
+	defer func(apply func(string, archive.Reader, *archive.TarOptions) (int64, error), copyWithTar func(string, string) error) {
+		graphdriver.ApplyUncompressedLayer = apply
+		vfs.CopyWithTar = copyWithTar
+	}(graphdriver.ApplyUncompressedLayer, vfs.CopyWithTar)
+	graphdriver.ApplyUncompressedLayer = archive.UnpackLayer
+	vfs.CopyWithTar = archive.CopyWithTar
+
+	tmp, err := ioutil.TempDir("", "tarexport-oci")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+
+	src := newTestExporter(t, filepath.Join(tmp, "src"))
+	rootFS := image.NewRootFS()
+	for _, name := range []string{"a", "b"} {
+		l, err := src.ls.Register(testLayerTar(t, name), rootFS.ChainID())
+		if err != nil {
+			t.Fatal(err)
+		}
+		rootFS.Append(l.DiffID())
+	}
+	config, err := json.Marshal(&image.Image{RootFS: rootFS})
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := src.is.Create(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	annotations := map[string]string{"org.opencontainers.image.version": "1.0"}
+	if err := src.is.SetAnnotations(id, annotations); err != nil {
+		t.Fatal(err)
+	}
+	named, err := reference.ParseNamed("example.com/test:v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := src.rs.AddTag(named, id, false); err != nil {
+		t.Fatal(err)
+	}
+
+	buf := &bytes.Buffer{}
+	if err := src.SaveOCI([]string{"example.com/test:v1"}, buf); err != nil {
+		t.Fatal(err)
+	}
+
+	// The archive is an OCI image layout whose blobs are named by the
+	// digests of the image config and layers.
+	files := make(map[string][]byte)
+	tr := tar.NewReader(bytes.NewReader(buf.Bytes()))
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := ioutil.ReadAll(tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[hdr.Name] = data
+	}
+	var index ociIndex
+	if err := json.Unmarshal(files[ociIndexFileName], &index); err != nil {
+		t.Fatal(err)
+	}
+	if len(index.Manifests) != 1 || index.Manifests[0].Annotations[ociImageNameAnnotation] != "example.com/test:v1" || index.Manifests[0].Annotations[ociRefNameAnnotation] != "v1" {
+		t.Fatalf("unexpected index %+v", index)
+	}
+	var manifest ociManifest
+	if err := json.Unmarshal(files[filepath.Join(ociBlobsDir, "sha256", index.Manifests[0].Digest.Hex())], &manifest); err != nil {
+		t.Fatal(err)
+	}
+	if manifest.Config.Digest != digest.Digest(id) || len(manifest.Layers) != 2 || manifest.Layers[1].Digest != digest.Digest(rootFS.DiffIDs[1]) {
+		t.Fatalf("unexpected manifest %+v", manifest)
+	}
+
+	dst := newTestExporter(t, filepath.Join(tmp, "dst"))
+	if err := dst.Load(ioutil.NopCloser(buf), ioutil.Discard, true); err != nil {
+		t.Fatal(err)
+	}
+	loadedID, err := dst.rs.Get(named)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loadedID != id {
+		t.Fatalf("expected the image ID to be preserved: expected %s, got %s", id, loadedID)
+	}
+	loadedAnnotations, err := dst.is.GetAnnotations(loadedID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(loadedAnnotations) != 1 || loadedAnnotations["org.opencontainers.image.version"] != "1.0" {
+		t.Fatalf("expected the annotations to be preserved, got %v", loadedAnnotations)
+	}
+}
+
+func TestOCIReference(t *testing.T) {
+	for _, tc := range []struct {
+		annotations map[string]string
+		expected    string
+	}{
+		{map[string]string{ociImageNameAnnotation: "docker.io/library/busybox:latest", ociRefNameAnnotation: "latest"}, "busybox:latest"},
+		{map[string]string{ociRefNameAnnotation: "example.com/test:v1"}, "example.com/test:v1"},
+		{map[string]string{ociRefNameAnnotation: "example.com/test"}, "example.com/test:latest"},
+		{map[string]string{ociRefNameAnnotation: "v1"}, ""},
+		{nil, ""},
+	} {
+		ref, err := ociReference(tc.annotations)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tc.expected == "" {
+			if ref != nil {
+				t.Fatalf("expected no reference for %v, got %s", tc.annotations, ref)
+			}
+			continue
+		}
+		if ref == nil || ref.String() != tc.expected {
+			t.Fatalf("expected reference %s for %v, got %v", tc.expected, tc.annotations, ref)
+		}
+	}
+}
@@ -77,6 +77,10 @@ func parseDockerDaemonHost(addr string) (string, error) {
 		return parseSimpleProtoAddr("npipe", addrParts[1], DefaultNamedPipe)
 	case "fd":
 		return addr, nil
+	case "ssh":
+		return parseSSHAddr(addrParts[1])
+	case "srv":
+		return parseSRVAddr(addrParts[1])
 	default:
 		return "", fmt.Errorf("Invalid bind address format: %s", addr)
 	}
@@ -97,6 +101,26 @@ func parseSimpleProtoAddr(proto, addr, defaultAddr string) (string, error) {
 	return fmt.Sprintf("%s://%s", proto, addr), nil
 }
 
+// parseSSHAddr validates that the specified address is a valid ssh address
+// in the form [user@]host[:port][/path/to/socket]. It returns the address
+// prefixed with ssh://.
+func parseSSHAddr(addr string) (string, error) {
+	if addr == "" || strings.Contains(addr, "://") || strings.HasPrefix(addr, "/") {
+		return "", fmt.Errorf("Invalid ssh address: %s", addr)
+	}
+	return "ssh://" + addr, nil
+}
+
+// parseSRVAddr validates that the specified address is a DNS name whose SRV
+// records list the daemons to connect to, like _docker._tcp.example.com.
+// It returns the address prefixed with srv://.
+func parseSRVAddr(addr string) (string, error) {
+	if addr == "" || strings.ContainsAny(addr, ":/") {
+		return "", fmt.Errorf("Invalid srv address: %s", addr)
+	}
+	return "srv://" + addr, nil
+}
+
 // parseTCPAddr parses and validates that the specified address is a valid TCP
 // address. It returns a formatted TCP address, either using the address parsed
 // from tryAddr, or the contents of defaultAddr if tryAddr is a blank string.
@@ -14,28 +14,36 @@ func TestParseHost(t *testing.T) {
 		"tcp://:port",
 		"tcp://invalid",
 		"tcp://invalid:port",
+		"ssh://",
+		"ssh:///var/run/docker.sock",
+		"srv://",
+		"srv://_docker._tcp.example.com:2376",
 	}
 
 	valid := map[string]string{
-		"":                         DefaultHost,
-		" ":                        DefaultHost,
-		"  ":                       DefaultHost,
-		"fd://":                    "fd://",
-		"fd://something":           "fd://something",
-		"tcp://host:":              fmt.Sprintf("tcp://host:%d", DefaultHTTPPort),
-		"tcp://":                   DefaultTCPHost,
-		"tcp://:2375":              fmt.Sprintf("tcp://%s:2375", DefaultHTTPHost),
-		"tcp://:2376":              fmt.Sprintf("tcp://%s:2376", DefaultHTTPHost),
-		"tcp://0.0.0.0:8080":       "tcp://0.0.0.0:8080",
-		"tcp://192.168.0.0:12000":  "tcp://192.168.0.0:12000",
-		"tcp://192.168:8080":       "tcp://192.168:8080",
-		"tcp://0.0.0.0:1234567890": "tcp://0.0.0.0:1234567890", // yeah it's valid :P
-		" tcp://:7777/path ":       fmt.Sprintf("tcp://%s:7777/path", DefaultHTTPHost),
-		"tcp://docker.com:2375":    "tcp://docker.com:2375",
-		"unix://":                  "unix://" + DefaultUnixSocket,
-		"unix://path/to/socket":    "unix://path/to/socket",
-		"npipe://":                 "npipe://" + DefaultNamedPipe,
-		"npipe:////./pipe/foo":     "npipe:////./pipe/foo",
+		"":                               DefaultHost,
+		" ":                              DefaultHost,
+		"  ":                             DefaultHost,
+		"fd://":                          "fd://",
+		"fd://something":                 "fd://something",
+		"tcp://host:":                    fmt.Sprintf("tcp://host:%d", DefaultHTTPPort),
+		"tcp://":                         DefaultTCPHost,
+		"tcp://:2375":                    fmt.Sprintf("tcp://%s:2375", DefaultHTTPHost),
+		"tcp://:2376":                    fmt.Sprintf("tcp://%s:2376", DefaultHTTPHost),
+		"tcp://0.0.0.0:8080":             "tcp://0.0.0.0:8080",
+		"tcp://192.168.0.0:12000":        "tcp://192.168.0.0:12000",
+		"tcp://192.168:8080":             "tcp://192.168:8080",
+		"tcp://0.0.0.0:1234567890":       "tcp://0.0.0.0:1234567890", // yeah it's valid :P
+		" tcp://:7777/path ":             fmt.Sprintf("tcp://%s:7777/path", DefaultHTTPHost),
+		"tcp://docker.com:2375":          "tcp://docker.com:2375",
+		"unix://":                        "unix://" + DefaultUnixSocket,
+		"unix://path/to/socket":          "unix://path/to/socket",
+		"npipe://":                       "npipe://" + DefaultNamedPipe,
+		"npipe:////./pipe/foo":           "npipe:////./pipe/foo",
+		"ssh://host":                     "ssh://host",
+		"ssh://user@host:2222":           "ssh://user@host:2222",
+		"ssh://user@host/run/d.sock":     "ssh://user@host/run/d.sock",
+		"srv://_docker._tcp.example.com": "srv://_docker._tcp.example.com",
 	}
 
 	for _, value := range invalid {
@@ -73,18 +81,18 @@ func TestParseDockerDaemonHost(t *testing.T) {
 		"[::1]:5555/path":             "tcp://[::1]:5555/path",
 		"[0:0:0:0:0:0:0:1]:":          "tcp://[0:0:0:0:0:0:0:1]:2375",
 		"[0:0:0:0:0:0:0:1]:5555/path": "tcp://[0:0:0:0:0:0:0:1]:5555/path",
-		":6666":                   fmt.Sprintf("tcp://%s:6666", DefaultHTTPHost),
-		":6666/path":              fmt.Sprintf("tcp://%s:6666/path", DefaultHTTPHost),
-		"tcp://":                  DefaultTCPHost,
-		"tcp://:7777":             fmt.Sprintf("tcp://%s:7777", DefaultHTTPHost),
-		"tcp://:7777/path":        fmt.Sprintf("tcp://%s:7777/path", DefaultHTTPHost),
-		"unix:///run/docker.sock": "unix:///run/docker.sock",
-		"unix://":                 "unix://" + DefaultUnixSocket,
-		"fd://":                   "fd://",
-		"fd://something":          "fd://something",
-		"localhost:":              "tcp://localhost:2375",
-		"localhost:5555":          "tcp://localhost:5555",
-		"localhost:5555/path":     "tcp://localhost:5555/path",
+		":6666":                       fmt.Sprintf("tcp://%s:6666", DefaultHTTPHost),
+		":6666/path":                  fmt.Sprintf("tcp://%s:6666/path", DefaultHTTPHost),
+		"tcp://":                      DefaultTCPHost,
+		"tcp://:7777":                 fmt.Sprintf("tcp://%s:7777", DefaultHTTPHost),
+		"tcp://:7777/path":            fmt.Sprintf("tcp://%s:7777/path", DefaultHTTPHost),
+		"unix:///run/docker.sock":     "unix:///run/docker.sock",
+		"unix://":                     "unix://" + DefaultUnixSocket,
+		"fd://":                       "fd://",
+		"fd://something":              "fd://something",
+		"localhost:":                  "tcp://localhost:2375",
+		"localhost:5555":              "tcp://localhost:5555",
+		"localhost:5555/path":         "tcp://localhost:5555/path",
 	}
 	for invalidAddr, expectedError := range invalids {
 		if addr, err := parseDockerDaemonHost(invalidAddr); err == nil || err.Error() != expectedError {
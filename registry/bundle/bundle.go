@@ -0,0 +1,273 @@
+// Package bundle reads and writes registry bundles. A bundle holds images
+// as they are stored in a registry, that is their manifests, configurations
+// and compressed layers, addressed by their digests. Bundles move images to
+// air-gapped hosts without changing their digests, so that their signatures
+// remain valid.
+//
+// A bundle is a tar archive in the OCI image layout: an oci-layout file, an
+// index.json file listing the manifests of the images along with their
+// references, and the blobs stored under blobs/<algorithm>/<hex>.
+package bundle
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/docker/distribution/digest"
+)
+
+const (
+	layoutFileName = "oci-layout"
+	indexFileName  = "index.json"
+	blobsDir       = "blobs"
+
+	// layoutVersion is the version of the OCI image layout of bundles.
+	layoutVersion = "1.0.0"
+
+	// RefNameAnnotation is the annotation holding the reference of an
+	// image in the index of a bundle.
+	RefNameAnnotation = "org.opencontainers.image.ref.name"
+
+	// maxManifestSize is the size of the largest manifest or image
+	// configuration read in memory.
+	maxManifestSize = 4 << 20
+)
+
+// Descriptor describes a blob of a bundle.
+type Descriptor struct {
+	MediaType   string            `json:"mediaType,omitempty"`
+	Digest      digest.Digest     `json:"digest"`
+	Size        int64             `json:"size"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// Index lists the images of a bundle.
+type Index struct {
+	SchemaVersion int          `json:"schemaVersion"`
+	Manifests     []Descriptor `json:"manifests"`
+}
+
+type imageLayout struct {
+	ImageLayoutVersion string `json:"imageLayoutVersion"`
+}
+
+// blobPath returns the path of a blob in a bundle.
+func blobPath(dgst digest.Digest) string {
+	return path.Join(blobsDir, string(dgst.Algorithm()), dgst.Hex())
+}
+
+// Writer writes a bundle.
+type Writer struct {
+	tw    *tar.Writer
+	blobs map[digest.Digest]bool
+	index Index
+}
+
+// NewWriter returns a Writer writing a bundle to w. Close must be called
+// once all the images are added.
+func NewWriter(w io.Writer) (*Writer, error) {
+	bw := &Writer{
+		tw:    tar.NewWriter(w),
+		blobs: make(map[digest.Digest]bool),
+		index: Index{SchemaVersion: 2},
+	}
+	layout, err := json.Marshal(imageLayout{ImageLayoutVersion: layoutVersion})
+	if err != nil {
+		return nil, err
+	}
+	if err := bw.writeFile(layoutFileName, layout); err != nil {
+		return nil, err
+	}
+	return bw, nil
+}
+
+func (bw *Writer) writeFile(name string, data []byte) error {
+	if err := bw.tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(data)), ModTime: time.Now()}); err != nil {
+		return err
+	}
+	_, err := bw.tw.Write(data)
+	return err
+}
+
+// HasBlob returns whether a blob was already written to the bundle.
+func (bw *Writer) HasBlob(dgst digest.Digest) bool {
+	return bw.blobs[dgst]
+}
+
+// WriteBlob writes a blob read from r to the bundle, checking that it
+// matches its descriptor. Blobs already in the bundle are skipped.
+func (bw *Writer) WriteBlob(desc Descriptor, r io.Reader) error {
+	if bw.blobs[desc.Digest] {
+		return nil
+	}
+	verifier, err := digest.NewDigestVerifier(desc.Digest)
+	if err != nil {
+		return err
+	}
+	if err := bw.tw.WriteHeader(&tar.Header{Name: blobPath(desc.Digest), Mode: 0644, Size: desc.Size, ModTime: time.Now()}); err != nil {
+		return err
+	}
+	n, err := io.Copy(bw.tw, io.TeeReader(io.LimitReader(r, desc.Size), verifier))
+	if err != nil {
+		return err
+	}
+	if n != desc.Size {
+		return fmt.Errorf("blob %s is %d bytes long, expected %d", desc.Digest, n, desc.Size)
+	}
+	if !verifier.Verified() {
+		return fmt.Errorf("blob %s does not match its digest", desc.Digest)
+	}
+	bw.blobs[desc.Digest] = true
+	return nil
+}
+
+// AddImage lists the manifest of an image in the index of the bundle, along
+// with its reference. The manifest must be written with WriteBlob.
+func (bw *Writer) AddImage(ref string, desc Descriptor) {
+	desc.Annotations = map[string]string{RefNameAnnotation: ref}
+	bw.index.Manifests = append(bw.index.Manifests, desc)
+}
+
+// Close writes the index of the bundle and closes it. It does not close the
+// underlying writer.
+func (bw *Writer) Close() error {
+	index, err := json.Marshal(bw.index)
+	if err != nil {
+		return err
+	}
+	if err := bw.writeFile(indexFileName, index); err != nil {
+		return err
+	}
+	return bw.tw.Close()
+}
+
+// blobSection is the location of a blob in a bundle.
+type blobSection struct {
+	offset int64
+	size   int64
+}
+
+// Reader reads a bundle.
+type Reader struct {
+	r     io.ReaderAt
+	index Index
+	blobs map[digest.Digest]blobSection
+}
+
+// NewReader reads the index of a bundle of the given size, and locates its
+// blobs.
+func NewReader(r io.ReaderAt, size int64) (*Reader, error) {
+	br := &Reader{r: r, blobs: make(map[digest.Digest]blobSection)}
+	cr := &countingReader{r: io.NewSectionReader(r, 0, size)}
+	tr := tar.NewReader(cr)
+	var hasLayout, hasIndex bool
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		name := path.Clean(hdr.Name)
+		switch {
+		case name == layoutFileName:
+			var layout imageLayout
+			if err := json.NewDecoder(tr).Decode(&layout); err != nil {
+				return nil, fmt.Errorf("invalid %s: %v", layoutFileName, err)
+			}
+			if layout.ImageLayoutVersion != layoutVersion {
+				return nil, fmt.Errorf("unsupported bundle layout version %q", layout.ImageLayoutVersion)
+			}
+			hasLayout = true
+		case name == indexFileName:
+			if err := json.NewDecoder(tr).Decode(&br.index); err != nil {
+				return nil, fmt.Errorf("invalid %s: %v", indexFileName, err)
+			}
+			hasIndex = true
+		case strings.HasPrefix(name, blobsDir+"/") && hdr.Typeflag == tar.TypeReg:
+			parts := strings.Split(name, "/")
+			if len(parts) != 3 {
+				continue
+			}
+			dgst := digest.NewDigestFromHex(parts[1], parts[2])
+			if err := dgst.Validate(); err != nil {
+				return nil, fmt.Errorf("invalid blob %s: %v", name, err)
+			}
+			br.blobs[dgst] = blobSection{offset: cr.n, size: hdr.Size}
+		}
+	}
+	if !hasLayout || !hasIndex {
+		return nil, fmt.Errorf("not a bundle, %s or %s is missing", layoutFileName, indexFileName)
+	}
+	return br, nil
+}
+
+// Index returns the index of the bundle.
+func (br *Reader) Index() Index {
+	return br.index
+}
+
+// HasBlob returns whether the bundle holds a blob.
+func (br *Reader) HasBlob(dgst digest.Digest) bool {
+	_, ok := br.blobs[dgst]
+	return ok
+}
+
+// Blob returns a reader of a blob, and its size.
+func (br *Reader) Blob(dgst digest.Digest) (io.Reader, int64, error) {
+	section, ok := br.blobs[dgst]
+	if !ok {
+		return nil, 0, fmt.Errorf("blob %s is not in the bundle", dgst)
+	}
+	return io.NewSectionReader(br.r, section.offset, section.size), section.size, nil
+}
+
+// ReadBlob reads a manifest or a configuration, and checks its digest.
+func (br *Reader) ReadBlob(dgst digest.Digest) ([]byte, error) {
+	r, size, err := br.Blob(dgst)
+	if err != nil {
+		return nil, err
+	}
+	if size > maxManifestSize {
+		return nil, fmt.Errorf("blob %s is too large", dgst)
+	}
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if digest.FromBytes(data) != dgst {
+		return nil, fmt.Errorf("blob %s does not match its digest", dgst)
+	}
+	return data, nil
+}
+
+// countingReader counts the bytes read from a reader.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.r.Read(p)
+	cr.n += int64(n)
+	return n, err
+}
+
+// readAll reads a manifest or a configuration of at most maxManifestSize.
+func readAll(r io.Reader) ([]byte, error) {
+	data, err := ioutil.ReadAll(io.LimitReader(r, maxManifestSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxManifestSize {
+		return nil, fmt.Errorf("manifest is larger than %d bytes", maxManifestSize)
+	}
+	return data, nil
+}
@@ -0,0 +1,259 @@
+package bundle
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"testing"
+
+	"github.com/docker/distribution/digest"
+	"github.com/docker/distribution/manifest/manifestlist"
+	"github.com/docker/distribution/manifest/schema2"
+)
+
+// fakeRepository is an in-memory repository.
+type fakeRepository struct {
+	blobs     map[digest.Digest][]byte
+	manifests map[string]Descriptor
+}
+
+func newFakeRepository() *fakeRepository {
+	return &fakeRepository{
+		blobs:     make(map[digest.Digest][]byte),
+		manifests: make(map[string]Descriptor),
+	}
+}
+
+func (r *fakeRepository) addBlob(mediaType string, data []byte) Descriptor {
+	desc := Descriptor{MediaType: mediaType, Digest: digest.FromBytes(data), Size: int64(len(data))}
+	r.blobs[desc.Digest] = data
+	return desc
+}
+
+func (r *fakeRepository) addManifest(tag, mediaType string, v interface{}) Descriptor {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	desc := r.addBlob(mediaType, payload)
+	r.manifests[desc.Digest.String()] = desc
+	if tag != "" {
+		r.manifests[tag] = desc
+	}
+	return desc
+}
+
+func (r *fakeRepository) Manifest(tagOrDigest string) (Descriptor, []byte, error) {
+	desc, ok := r.manifests[tagOrDigest]
+	if !ok {
+		return Descriptor{}, nil, fmt.Errorf("manifest %s not found", tagOrDigest)
+	}
+	return desc, r.blobs[desc.Digest], nil
+}
+
+func (r *fakeRepository) Blob(dgst digest.Digest) (io.ReadCloser, error) {
+	data, ok := r.blobs[dgst]
+	if !ok {
+		return nil, fmt.Errorf("blob %s not found", dgst)
+	}
+	return ioutil.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (r *fakeRepository) HasBlob(dgst digest.Digest) (bool, error) {
+	_, ok := r.blobs[dgst]
+	return ok, nil
+}
+
+func (r *fakeRepository) PutBlob(desc Descriptor, content io.Reader) error {
+	data, err := ioutil.ReadAll(content)
+	if err != nil {
+		return err
+	}
+	if digest.FromBytes(data) != desc.Digest {
+		return fmt.Errorf("blob %s does not match its digest", desc.Digest)
+	}
+	r.blobs[desc.Digest] = data
+	return nil
+}
+
+func (r *fakeRepository) PutManifest(tagOrDigest string, desc Descriptor, payload []byte) error {
+	if digest.FromBytes(payload) != desc.Digest {
+		return fmt.Errorf("manifest %s does not match its digest", desc.Digest)
+	}
+	r.blobs[desc.Digest] = payload
+	r.manifests[desc.Digest.String()] = desc
+	r.manifests[tagOrDigest] = desc
+	return nil
+}
+
+// addImage adds an image manifest with a configuration and a layer.
+func (r *fakeRepository) addImage(tag, name string) Descriptor {
+	config := r.addBlob(schema2.MediaTypeConfig, []byte(`{"name":"`+name+`"}`))
+	layer := r.addBlob(schema2.MediaTypeLayer, []byte("layer of "+name))
+	return r.addManifest(tag, schema2.MediaTypeManifest, manifest{
+		SchemaVersion: 2,
+		MediaType:     schema2.MediaTypeManifest,
+		Config:        &config,
+		Layers:        []Descriptor{layer},
+	})
+}
+
+func newTestRepository() *fakeRepository {
+	repo := newFakeRepository()
+	repo.addImage("single", "single")
+	amd64 := repo.addImage("", "amd64")
+	arm := repo.addImage("", "arm")
+	repo.addManifest("list", manifestlist.MediaTypeManifestList, manifest{
+		SchemaVersion: 2,
+		MediaType:     manifestlist.MediaTypeManifestList,
+		Manifests: []manifestDescriptor{
+			{Descriptor: amd64, Platform: &platformSpec{OS: "linux", Architecture: "amd64"}},
+			{Descriptor: arm, Platform: &platformSpec{OS: "linux", Architecture: "arm"}},
+		},
+	})
+	return repo
+}
+
+func exportTestBundle(t *testing.T, src *fakeRepository) *Reader {
+	var buf bytes.Buffer
+	bw, err := NewWriter(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tag := range []string{"single", "list"} {
+		if err := Export(bw, src, "example.com/foo:"+tag, tag, ioutil.Discard); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := bw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	br, err := NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return br
+}
+
+func TestExportPush(t *testing.T) {
+	src := newTestRepository()
+	br := exportTestBundle(t, src)
+
+	index := br.Index()
+	if len(index.Manifests) != 2 {
+		t.Fatalf("expected 2 images in the index, got %d", len(index.Manifests))
+	}
+	for _, desc := range index.Manifests {
+		if !br.HasBlob(desc.Digest) {
+			t.Fatalf("manifest %s is missing from the bundle", desc.Digest)
+		}
+	}
+
+	dst := newFakeRepository()
+	for i, tag := range []string{"single", "list"} {
+		desc := index.Manifests[i]
+		desc.Annotations = nil
+		if err := Push(br, desc, dst, tag, ioutil.Discard); err != nil {
+			t.Fatal(err)
+		}
+		if dst.manifests[tag].Digest != src.manifests[tag].Digest {
+			t.Fatalf("%s: expected digest %s, got %s", tag, src.manifests[tag].Digest, dst.manifests[tag].Digest)
+		}
+	}
+	if !reflect.DeepEqual(src.blobs, dst.blobs) {
+		t.Fatal("the blobs of the pushed repository do not match the exported ones")
+	}
+}
+
+func TestExportSchema1(t *testing.T) {
+	src := newFakeRepository()
+	src.addManifest("old", "application/vnd.docker.distribution.manifest.v1+prettyjws", map[string]interface{}{"schemaVersion": 1})
+
+	bw, err := NewWriter(ioutil.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Export(bw, src, "example.com/foo:old", "old", ioutil.Discard); err == nil {
+		t.Fatal("expected schema1 manifests to be rejected")
+	}
+}
+
+func TestNewReaderInvalid(t *testing.T) {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	tw.Close()
+	if _, err := NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len())); err == nil {
+		t.Fatal("expected an archive without an index to be rejected")
+	}
+}
+
+func TestWriteDockerArchive(t *testing.T) {
+	src := newTestRepository()
+	br := exportTestBundle(t, src)
+
+	var buf bytes.Buffer
+	if err := WriteDockerArchive(&buf, br, "linux", "arm"); err != nil {
+		t.Fatal(err)
+	}
+
+	files := make(map[string][]byte)
+	tr := tar.NewReader(&buf)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := ioutil.ReadAll(tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[hdr.Name] = data
+	}
+
+	var items []dockerArchiveItem
+	if err := json.Unmarshal(files[dockerArchiveManifestFileName], &items); err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 2 {
+		t.Fatalf("expected 2 images, got %d", len(items))
+	}
+	for i, expected := range []struct{ tag, config string }{
+		{"example.com/foo:single", `{"name":"single"}`},
+		{"example.com/foo:list", `{"name":"arm"}`},
+	} {
+		item := items[i]
+		if !reflect.DeepEqual(item.RepoTags, []string{expected.tag}) {
+			t.Fatalf("expected tags %v, got %v", []string{expected.tag}, item.RepoTags)
+		}
+		if config := string(files[item.Config]); config != expected.config {
+			t.Fatalf("%s: expected configuration %s, got %s", expected.tag, expected.config, config)
+		}
+		if len(item.Layers) != 1 || files[item.Layers[0]] == nil {
+			t.Fatalf("%s: layers are missing from the archive: %v", expected.tag, item.Layers)
+		}
+	}
+
+	if err := WriteDockerArchive(ioutil.Discard, br, "windows", "amd64"); err == nil {
+		t.Fatal("expected an error for a platform missing from the manifest list")
+	}
+}
+
+func TestIsTagged(t *testing.T) {
+	for ref, expected := range map[string]bool{
+		"example.com/foo:latest":                                true,
+		"localhost:5000/foo":                                    false,
+		"localhost:5000/foo:latest":                             true,
+		"example.com/foo@sha256:" + digest.FromBytes(nil).Hex(): false,
+	} {
+		if actual := isTagged(ref); actual != expected {
+			t.Errorf("%s: expected %v, got %v", ref, expected, actual)
+		}
+	}
+}
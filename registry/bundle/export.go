@@ -0,0 +1,77 @@
+package bundle
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/docker/distribution/digest"
+)
+
+// Source is a repository images are exported from.
+type Source interface {
+	// Manifest returns the descriptor and payload of a manifest.
+	Manifest(tagOrDigest string) (Descriptor, []byte, error)
+	// Blob returns the content of a blob.
+	Blob(dgst digest.Digest) (io.ReadCloser, error)
+}
+
+// Export writes an image of a repository to a bundle, with the manifests of
+// all its platforms if it is a manifest list. ref is the reference listed in
+// the index of the bundle, and tagOrDigest the tag or digest of the image in
+// the repository. Progress messages are written to out.
+func Export(bw *Writer, src Source, ref, tagOrDigest string, out io.Writer) error {
+	desc, payload, err := src.Manifest(tagOrDigest)
+	if err != nil {
+		return err
+	}
+	if err := exportManifest(bw, src, desc, payload, out); err != nil {
+		return fmt.Errorf("exporting %s: %v", ref, err)
+	}
+	bw.AddImage(ref, desc)
+	fmt.Fprintf(out, "%s: exported %s\n", ref, desc.Digest)
+	return nil
+}
+
+// exportManifest writes a manifest and the blobs it references to a bundle.
+func exportManifest(bw *Writer, src Source, desc Descriptor, payload []byte, out io.Writer) error {
+	m, err := parseManifest(desc.MediaType, payload)
+	if err != nil {
+		return err
+	}
+	if m.isList() {
+		for _, child := range m.Manifests {
+			if bw.HasBlob(child.Digest) {
+				continue
+			}
+			childDesc, childPayload, err := src.Manifest(child.Digest.String())
+			if err != nil {
+				return err
+			}
+			if err := exportManifest(bw, src, childDesc, childPayload, out); err != nil {
+				return err
+			}
+		}
+	} else {
+		for _, blob := range m.blobs() {
+			if err := exportBlob(bw, src, blob, out); err != nil {
+				return err
+			}
+		}
+	}
+	return bw.WriteBlob(desc, bytes.NewReader(payload))
+}
+
+// exportBlob writes a blob to a bundle, if it is not already in it.
+func exportBlob(bw *Writer, src Source, desc Descriptor, out io.Writer) error {
+	if bw.HasBlob(desc.Digest) {
+		return nil
+	}
+	fmt.Fprintf(out, "%s: exporting %d bytes\n", desc.Digest.Hex()[:12], desc.Size)
+	rc, err := src.Blob(desc.Digest)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	return bw.WriteBlob(desc, rc)
+}
@@ -0,0 +1,123 @@
+package bundle
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/docker/distribution/digest"
+	"github.com/docker/docker/reference"
+)
+
+// dockerArchiveManifestFileName is the name of the file listing the images of
+// the archives read by docker load.
+const dockerArchiveManifestFileName = "manifest.json"
+
+// dockerArchiveItem describes an image of an archive read by docker load.
+type dockerArchiveItem struct {
+	Config   string
+	RepoTags []string
+	Layers   []string
+}
+
+// WriteDockerArchive converts the images of a bundle to an archive read by
+// docker load. The manifest matching the given operating system and
+// architecture is picked from the manifest lists. Only the references of the
+// bundle which are tags are kept, images exported by digest are loaded
+// untagged.
+func WriteDockerArchive(w io.Writer, br *Reader, os, arch string) error {
+	tw := tar.NewWriter(w)
+	written := make(map[digest.Digest]bool)
+	writeBlob := func(dgst digest.Digest) (string, error) {
+		name := blobPath(dgst)
+		if written[dgst] {
+			return name, nil
+		}
+		r, size, err := br.Blob(dgst)
+		if err != nil {
+			return "", err
+		}
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: size, ModTime: time.Now()}); err != nil {
+			return "", err
+		}
+		if _, err := io.Copy(tw, r); err != nil {
+			return "", err
+		}
+		written[dgst] = true
+		return name, nil
+	}
+
+	var items []dockerArchiveItem
+	for _, desc := range br.Index().Manifests {
+		ref := desc.Annotations[RefNameAnnotation]
+		m, err := resolvePlatform(br, desc, os, arch)
+		if err != nil {
+			return fmt.Errorf("%s: %v", ref, err)
+		}
+		var item dockerArchiveItem
+		if item.Config, err = writeBlob(m.Config.Digest); err != nil {
+			return err
+		}
+		for _, layer := range m.Layers {
+			if layer.MediaType == mediaTypeForeignLayer {
+				return fmt.Errorf("%s: images with foreign layers cannot be imported, push them to a registry instead", ref)
+			}
+			name, err := writeBlob(layer.Digest)
+			if err != nil {
+				return err
+			}
+			item.Layers = append(item.Layers, name)
+		}
+		if isTagged(ref) {
+			item.RepoTags = []string{ref}
+		}
+		items = append(items, item)
+	}
+
+	manifest, err := json.Marshal(items)
+	if err != nil {
+		return err
+	}
+	if err := tw.WriteHeader(&tar.Header{Name: dockerArchiveManifestFileName, Mode: 0644, Size: int64(len(manifest)), ModTime: time.Now()}); err != nil {
+		return err
+	}
+	if _, err := tw.Write(manifest); err != nil {
+		return err
+	}
+	return tw.Close()
+}
+
+// resolvePlatform returns the image manifest of a bundle for a platform,
+// picking it from the manifest list if the image has one.
+func resolvePlatform(br *Reader, desc Descriptor, os, arch string) (*manifest, error) {
+	m, err := readManifest(br, desc)
+	if err != nil {
+		return nil, err
+	}
+	if !m.isList() {
+		return m, nil
+	}
+	var available []string
+	for _, child := range m.Manifests {
+		if child.Platform == nil {
+			continue
+		}
+		if child.Platform.OS == os && child.Platform.Architecture == arch {
+			return readManifest(br, child.Descriptor)
+		}
+		available = append(available, child.Platform.OS+"/"+child.Platform.Architecture)
+	}
+	return nil, fmt.Errorf("no image for %s/%s in the manifest list, available platforms: %v", os, arch, available)
+}
+
+// isTagged returns whether a reference ends with a tag rather than a digest.
+func isTagged(ref string) bool {
+	named, err := reference.ParseNamed(ref)
+	if err != nil {
+		return false
+	}
+	_, ok := named.(reference.NamedTagged)
+	return ok
+}
@@ -0,0 +1,110 @@
+package bundle
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/docker/distribution/digest"
+	"github.com/docker/distribution/manifest/manifestlist"
+	"github.com/docker/distribution/manifest/schema1"
+	"github.com/docker/distribution/manifest/schema2"
+)
+
+const (
+	// MediaTypeOCIIndex is the media type of OCI image indexes.
+	MediaTypeOCIIndex = "application/vnd.oci.image.index.v1+json"
+	// MediaTypeOCIManifest is the media type of OCI image manifests.
+	MediaTypeOCIManifest = "application/vnd.oci.image.manifest.v1+json"
+
+	// mediaTypeForeignLayer is the media type of the layers that are not
+	// distributed by registries, like the base layers of Windows images.
+	mediaTypeForeignLayer = "application/vnd.docker.image.rootfs.foreign.diff.tar.gzip"
+)
+
+// manifestMediaTypes are the media types of the manifests bundles hold.
+var manifestMediaTypes = []string{
+	schema2.MediaTypeManifest,
+	manifestlist.MediaTypeManifestList,
+	MediaTypeOCIManifest,
+	MediaTypeOCIIndex,
+}
+
+// errSchema1 is returned for schema1 manifests, which are signed by the
+// registry for its own name and cannot be moved to another one.
+var errSchema1 = errors.New("schema1 manifests are not supported in bundles, the image must be pushed again with a recent version of Docker")
+
+// platformSpec is the platform of a manifest of a manifest list.
+type platformSpec struct {
+	Architecture string `json:"architecture"`
+	OS           string `json:"os"`
+	Variant      string `json:"variant,omitempty"`
+}
+
+// manifestDescriptor references a manifest from a manifest list.
+type manifestDescriptor struct {
+	Descriptor
+	Platform *platformSpec `json:"platform,omitempty"`
+}
+
+// manifest holds the references to other blobs of image manifests, manifest
+// lists and their OCI counterparts.
+type manifest struct {
+	SchemaVersion int                  `json:"schemaVersion"`
+	MediaType     string               `json:"mediaType,omitempty"`
+	Config        *Descriptor          `json:"config,omitempty"`
+	Layers        []Descriptor         `json:"layers,omitempty"`
+	Manifests     []manifestDescriptor `json:"manifests,omitempty"`
+}
+
+// isList returns whether the manifest is a manifest list or an OCI index.
+func (m *manifest) isList() bool {
+	return m.Config == nil
+}
+
+// blobs returns the blobs an image manifest references, without the
+// foreign layers.
+func (m *manifest) blobs() []Descriptor {
+	blobs := []Descriptor{*m.Config}
+	for _, layer := range m.Layers {
+		if layer.MediaType != mediaTypeForeignLayer {
+			blobs = append(blobs, layer)
+		}
+	}
+	return blobs
+}
+
+// parseManifest decodes a manifest of the given media type.
+func parseManifest(mediaType string, payload []byte) (*manifest, error) {
+	if mediaType == schema1.MediaTypeManifest || mediaType == schema1.MediaTypeSignedManifest {
+		return nil, errSchema1
+	}
+	var m manifest
+	if err := json.Unmarshal(payload, &m); err != nil {
+		return nil, fmt.Errorf("invalid manifest: %v", err)
+	}
+	if m.SchemaVersion == 1 {
+		return nil, errSchema1
+	}
+	if m.SchemaVersion != 2 {
+		return nil, fmt.Errorf("unsupported manifest schema version %d", m.SchemaVersion)
+	}
+	if m.Config == nil && m.Manifests == nil {
+		return nil, errors.New("invalid manifest, it references neither an image configuration nor manifests")
+	}
+	return &m, nil
+}
+
+// manifestDescriptorOf returns the descriptor of a manifest payload.
+func manifestDescriptorOf(mediaType string, payload []byte) Descriptor {
+	return Descriptor{MediaType: mediaType, Digest: digest.FromBytes(payload), Size: int64(len(payload))}
+}
+
+// readManifest reads and decodes a manifest of a bundle.
+func readManifest(br *Reader, desc Descriptor) (*manifest, error) {
+	payload, err := br.ReadBlob(desc.Digest)
+	if err != nil {
+		return nil, err
+	}
+	return parseManifest(desc.MediaType, payload)
+}
@@ -0,0 +1,88 @@
+package bundle
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/docker/distribution/digest"
+)
+
+// Target is a repository images are pushed to.
+type Target interface {
+	// HasBlob returns whether the repository holds a blob.
+	HasBlob(dgst digest.Digest) (bool, error)
+	// PutBlob uploads a blob.
+	PutBlob(desc Descriptor, content io.Reader) error
+	// PutManifest uploads a manifest under a tag or its digest.
+	PutManifest(tagOrDigest string, desc Descriptor, payload []byte) error
+}
+
+// Push pushes an image of a bundle, described by its entry in the index of
+// the bundle, to a repository under a tag, or its digest if tag is empty.
+// The manifests and blobs are pushed unchanged, so the image keeps its
+// digest. Progress messages are written to out.
+func Push(br *Reader, desc Descriptor, dst Target, tag string, out io.Writer) error {
+	if err := pushManifest(br, desc, dst, out); err != nil {
+		return err
+	}
+	if tag == "" {
+		tag = desc.Digest.String()
+	}
+	payload, err := br.ReadBlob(desc.Digest)
+	if err != nil {
+		return err
+	}
+	if err := dst.PutManifest(tag, desc, payload); err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "%s: pushed %s\n", tag, desc.Digest)
+	return nil
+}
+
+// pushManifest pushes the blobs and manifests a manifest references.
+func pushManifest(br *Reader, desc Descriptor, dst Target, out io.Writer) error {
+	m, err := readManifest(br, desc)
+	if err != nil {
+		return err
+	}
+	if m.isList() {
+		for _, child := range m.Manifests {
+			if err := pushManifest(br, child.Descriptor, dst, out); err != nil {
+				return err
+			}
+			childPayload, err := br.ReadBlob(child.Digest)
+			if err != nil {
+				return err
+			}
+			if err := dst.PutManifest(child.Digest.String(), child.Descriptor, childPayload); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for _, blob := range m.blobs() {
+		if err := pushBlob(br, blob, dst, out); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pushBlob uploads a blob of a bundle, unless the repository holds it.
+func pushBlob(br *Reader, desc Descriptor, dst Target, out io.Writer) error {
+	exists, err := dst.HasBlob(desc.Digest)
+	if err != nil {
+		return err
+	}
+	if exists {
+		fmt.Fprintf(out, "%s: already exists\n", desc.Digest.Hex()[:12])
+		return nil
+	}
+	r, size, err := br.Blob(desc.Digest)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "%s: pushing %d bytes\n", desc.Digest.Hex()[:12], size)
+	desc.Size = size
+	return dst.PutBlob(desc, r)
+}
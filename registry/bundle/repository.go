@@ -0,0 +1,279 @@
+package bundle
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/docker/distribution/digest"
+	"github.com/docker/distribution/registry/client"
+	"github.com/docker/distribution/registry/client/auth"
+	"github.com/docker/distribution/registry/client/transport"
+	"github.com/docker/docker/registry"
+	"github.com/docker/engine-api/types"
+)
+
+// Repository reads and writes the manifests and blobs of a repository of a
+// registry, leaving them byte for byte unchanged.
+type Repository struct {
+	name    string
+	baseURL *url.URL
+	client  *http.Client
+}
+
+// credentialStore returns the credentials of the user to the token handlers.
+type credentialStore struct {
+	auth types.AuthConfig
+}
+
+func (cs credentialStore) Basic(*url.URL) (string, string) {
+	return cs.auth.Username, cs.auth.Password
+}
+
+func (cs credentialStore) RefreshToken(*url.URL, string) string {
+	return cs.auth.IdentityToken
+}
+
+func (cs credentialStore) SetRefreshToken(*url.URL, string, string) {
+}
+
+// NewRepository connects to the first v2 endpoint of a repository that
+// answers, with the given credentials and the access to the repository
+// requested by actions, like "pull" or "push".
+func NewRepository(service *registry.Service, repoInfo *registry.RepositoryInfo, authConfig types.AuthConfig, userAgent string, actions ...string) (*Repository, error) {
+	endpoints, err := service.LookupPushEndpoints(repoInfo.Hostname())
+	if err != nil {
+		return nil, err
+	}
+	lastErr := fmt.Errorf("no v2 endpoint found for %s", repoInfo.Hostname())
+	for _, endpoint := range endpoints {
+		if endpoint.Version != registry.APIVersion2 {
+			continue
+		}
+		repo, err := newRepository(endpoint, repoInfo, authConfig, userAgent, actions)
+		if err != nil {
+			logrus.Debugf("Error connecting to %s: %v", endpoint.URL, err)
+			lastErr = err
+			continue
+		}
+		return repo, nil
+	}
+	return nil, lastErr
+}
+
+func newRepository(endpoint registry.APIEndpoint, repoInfo *registry.RepositoryInfo, authConfig types.AuthConfig, userAgent string, actions []string) (*Repository, error) {
+	name := repoInfo.FullName()
+	if endpoint.TrimHostname {
+		name = repoInfo.RemoteName()
+	}
+
+	base := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		Dial: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+			DualStack: true,
+		}).Dial,
+		TLSHandshakeTimeout: 10 * time.Second,
+		TLSClientConfig:     endpoint.TLSConfig,
+	}
+	modifiers := registry.DockerHeaders(userAgent, nil)
+	authTransport := transport.NewTransport(base, modifiers...)
+
+	challengeManager, _, err := registry.PingV2Registry(endpoint, authTransport)
+	if err != nil {
+		if responseErr, ok := err.(registry.PingResponseError); ok {
+			err = responseErr.Err
+		}
+		return nil, err
+	}
+
+	creds := credentialStore{auth: authConfig}
+	tokenHandler := auth.NewTokenHandlerWithOptions(auth.TokenHandlerOptions{
+		Transport:   authTransport,
+		Credentials: creds,
+		Scopes:      []auth.Scope{auth.RepositoryScope{Repository: name, Actions: actions}},
+		ClientID:    registry.AuthClientID,
+	})
+	modifiers = append(modifiers, auth.NewAuthorizer(challengeManager, tokenHandler, auth.NewBasicHandler(creds)))
+
+	return &Repository{
+		name:    name,
+		baseURL: endpoint.URL,
+		client:  &http.Client{Transport: transport.NewTransport(base, modifiers...)},
+	}, nil
+}
+
+// Name returns the name of the repository on its registry.
+func (r *Repository) Name() string {
+	return r.name
+}
+
+// url returns the URL of a path of the repository, like manifests/latest.
+func (r *Repository) url(p string) string {
+	return strings.TrimSuffix(r.baseURL.String(), "/") + "/v2/" + r.name + "/" + p
+}
+
+// do sends a request, and turns the responses that are not successful into
+// errors.
+func (r *Repository) do(req *http.Request) (*http.Response, error) {
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if !client.SuccessStatus(resp.StatusCode) {
+		defer resp.Body.Close()
+		return nil, client.HandleErrorResponse(resp)
+	}
+	return resp, nil
+}
+
+// Manifest fetches a manifest by tag or digest, and returns its descriptor
+// and payload.
+func (r *Repository) Manifest(tagOrDigest string) (Descriptor, []byte, error) {
+	req, err := http.NewRequest("GET", r.url("manifests/"+tagOrDigest), nil)
+	if err != nil {
+		return Descriptor{}, nil, err
+	}
+	for _, mediaType := range manifestMediaTypes {
+		req.Header.Add("Accept", mediaType)
+	}
+	resp, err := r.do(req)
+	if err != nil {
+		return Descriptor{}, nil, err
+	}
+	defer resp.Body.Close()
+	payload, err := readAll(resp.Body)
+	if err != nil {
+		return Descriptor{}, nil, err
+	}
+
+	mediaType := resp.Header.Get("Content-Type")
+	if i := strings.Index(mediaType, ";"); i >= 0 {
+		mediaType = strings.TrimSpace(mediaType[:i])
+	}
+	desc := manifestDescriptorOf(mediaType, payload)
+	if dgst, err := digest.ParseDigest(tagOrDigest); err == nil && dgst != desc.Digest {
+		return Descriptor{}, nil, fmt.Errorf("manifest %s does not match its digest", dgst)
+	}
+	return desc, payload, nil
+}
+
+// Blob fetches a blob.
+func (r *Repository) Blob(dgst digest.Digest) (io.ReadCloser, error) {
+	req, err := http.NewRequest("GET", r.url("blobs/"+dgst.String()), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := r.do(req)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// HasBlob returns whether the repository holds a blob.
+func (r *Repository) HasBlob(dgst digest.Digest) (bool, error) {
+	req, err := http.NewRequest("HEAD", r.url("blobs/"+dgst.String()), nil)
+	if err != nil {
+		return false, err
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return false, nil
+	case client.SuccessStatus(resp.StatusCode):
+		return true, nil
+	}
+	return false, fmt.Errorf("checking blob %s: unexpected status %s", dgst, resp.Status)
+}
+
+// PutBlob uploads a blob in a single request.
+func (r *Repository) PutBlob(desc Descriptor, content io.Reader) error {
+	req, err := http.NewRequest("POST", r.url("blobs/uploads/"), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := r.do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	location, err := resp.Location()
+	if err != nil {
+		return fmt.Errorf("uploading blob %s: %v", desc.Digest, err)
+	}
+	query := location.Query()
+	query.Set("digest", desc.Digest.String())
+	location.RawQuery = query.Encode()
+
+	req, err = http.NewRequest("PUT", location.String(), content)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = desc.Size
+	req.Header.Set("Content-Type", "application/octet-stream")
+	resp, err = r.do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// MountBlob mounts a blob of another repository of the registry, and returns
+// whether the registry mounted it. Registries which do not mount blobs, or
+// do not allow reading the other repository, start an upload instead, which
+// is cancelled.
+func (r *Repository) MountBlob(dgst digest.Digest, from string) (bool, error) {
+	query := url.Values{}
+	query.Set("mount", dgst.String())
+	query.Set("from", from)
+	req, err := http.NewRequest("POST", r.url("blobs/uploads/")+"?"+query.Encode(), nil)
+	if err != nil {
+		return false, err
+	}
+	resp, err := r.do(req)
+	if err != nil {
+		return false, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusCreated {
+		return true, nil
+	}
+
+	if location, err := resp.Location(); err == nil {
+		if req, err := http.NewRequest("DELETE", location.String(), nil); err == nil {
+			if resp, err := r.client.Do(req); err == nil {
+				resp.Body.Close()
+			}
+		}
+	}
+	return false, nil
+}
+
+// PutManifest uploads a manifest under a tag or its digest.
+func (r *Repository) PutManifest(tagOrDigest string, desc Descriptor, payload []byte) error {
+	req, err := http.NewRequest("PUT", r.url("manifests/"+tagOrDigest), bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", desc.MediaType)
+	resp, err := r.do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
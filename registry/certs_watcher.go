@@ -0,0 +1,171 @@
+package registry
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/docker/docker/pkg/filenotify"
+	"gopkg.in/fsnotify.v1"
+)
+
+// hostCerts holds the certificates read from the directory of a host.
+type hostCerts struct {
+	rootCAs      *x509.CertPool
+	certificates []tls.Certificate
+}
+
+// certsCache keeps the certificates of the host directories of CertsDir
+// while a watcher drops them on change. Without a watcher, the directories
+// are read every time a TLS configuration is built.
+type certsCache struct {
+	mu      sync.Mutex
+	enabled bool
+	// generation is incremented on every change, so that certificates read
+	// while a directory changes are not cached.
+	generation uint64
+	dirs       map[string]*hostCerts
+}
+
+var registryCerts = &certsCache{}
+
+// load adds the certificates of a host directory to a TLS configuration.
+func (c *certsCache) load(tlsConfig *tls.Config, directory string) error {
+	c.mu.Lock()
+	enabled, generation := c.enabled, c.generation
+	certs, ok := c.dirs[directory]
+	c.mu.Unlock()
+	if !enabled || tlsConfig.RootCAs != nil {
+		return ReadCertsDirectory(tlsConfig, directory)
+	}
+
+	if !ok {
+		var read tls.Config
+		if err := ReadCertsDirectory(&read, directory); err != nil {
+			return err
+		}
+		certs = &hostCerts{rootCAs: read.RootCAs, certificates: read.Certificates}
+		c.mu.Lock()
+		if c.enabled && c.generation == generation {
+			c.dirs[directory] = certs
+		}
+		c.mu.Unlock()
+	}
+
+	// The pool is shared by the configurations of a host, and never modified.
+	tlsConfig.RootCAs = certs.rootCAs
+	tlsConfig.Certificates = append(tlsConfig.Certificates, certs.certificates...)
+	return nil
+}
+
+// invalidate drops the cached certificates of a directory, or of all of
+// them if directory is empty.
+func (c *certsCache) invalidate(directory string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.generation++
+	if directory == "" {
+		c.dirs = make(map[string]*hostCerts)
+		return
+	}
+	delete(c.dirs, directory)
+}
+
+// setEnabled turns the cache on or off, emptying it.
+func (c *certsCache) setEnabled(enabled bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.enabled = enabled
+	c.generation++
+	c.dirs = make(map[string]*hostCerts)
+}
+
+// StartCertsWatcher watches CertsDir with inotify until the returned
+// function is called. Meanwhile, the CA and client certificates of the
+// registries are read once and reloaded when they change, new registry
+// connections using the new ones without a daemon restart.
+func StartCertsWatcher() (stop func(), err error) {
+	if CertsDir == "" {
+		return func() {}, nil
+	}
+	if _, err := os.Stat(CertsDir); os.IsNotExist(err) {
+		logrus.Debugf("Not watching registry certificates, %s does not exist", CertsDir)
+		return func() {}, nil
+	}
+	watcher, err := filenotify.NewEventWatcher()
+	if err != nil {
+		return func() {}, err
+	}
+	if err := watchCertsDir(watcher); err != nil {
+		watcher.Close()
+		return func() {}, err
+	}
+
+	registryCerts.setEnabled(true)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case event := <-watcher.Events():
+				handleCertsEvent(watcher, event)
+			case err := <-watcher.Errors():
+				logrus.Warnf("Error watching registry certificates: %v", err)
+				registryCerts.invalidate("")
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(done)
+			watcher.Close()
+			registryCerts.setEnabled(false)
+		})
+	}, nil
+}
+
+// watchCertsDir watches CertsDir and its host directories, as inotify
+// watches are not recursive.
+func watchCertsDir(watcher filenotify.FileWatcher) error {
+	if err := watcher.Add(CertsDir); err != nil {
+		return err
+	}
+	fs, err := ioutil.ReadDir(CertsDir)
+	if err != nil {
+		return err
+	}
+	for _, f := range fs {
+		if f.IsDir() {
+			if err := watcher.Add(filepath.Join(CertsDir, f.Name())); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// handleCertsEvent drops the certificates of the host directory an event
+// relates to, and starts watching the host directories being created.
+func handleCertsEvent(watcher filenotify.FileWatcher, event fsnotify.Event) {
+	directory := filepath.Dir(event.Name)
+	if directory == filepath.Clean(CertsDir) {
+		// The host directory itself changed.
+		directory = event.Name
+		if event.Op&fsnotify.Create != 0 {
+			if fi, err := os.Stat(event.Name); err == nil && fi.IsDir() {
+				if err := watcher.Add(event.Name); err != nil {
+					logrus.Warnf("Error watching registry certificates in %s: %v", event.Name, err)
+				}
+			}
+		}
+	}
+	logrus.Debugf("Reloading registry certificates from %s", directory)
+	registryCerts.invalidate(directory)
+}
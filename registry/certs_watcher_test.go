@@ -0,0 +1,90 @@
+package registry
+
+import (
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCertsWatcherReloadsCertificates(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "registry-certs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	defer func(old string) { CertsDir = old }(CertsDir)
+	CertsDir = tmpDir
+
+	hostDir := filepath.Join(tmpDir, "registry.example.com:5000")
+	if err := os.Mkdir(hostDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	stop, err := StartCertsWatcher()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+
+	tlsConfig, err := newTLSConfig("registry.example.com:5000", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tlsConfig.RootCAs != nil {
+		t.Fatal("expected no CA certificate")
+	}
+
+	srv := httptest.NewTLSServer(http.NotFoundHandler())
+	defer srv.Close()
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.TLS.Certificates[0].Certificate[0]})
+	if err := ioutil.WriteFile(filepath.Join(hostDir, "ca.crt"), ca, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		tlsConfig, err = newTLSConfig("registry.example.com:5000", true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tlsConfig.RootCAs != nil && len(tlsConfig.RootCAs.Subjects()) == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the new CA certificate was not loaded")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Host directories created after the watcher started are watched too.
+	otherDir := filepath.Join(tmpDir, "other.example.com")
+	if err := os.Mkdir(otherDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := newTLSConfig("other.example.com", true); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if err := ioutil.WriteFile(filepath.Join(otherDir, "ca.crt"), ca, 0644); err != nil {
+		t.Fatal(err)
+	}
+	deadline = time.Now().Add(5 * time.Second)
+	for {
+		tlsConfig, err = newTLSConfig("other.example.com", true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tlsConfig.RootCAs != nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the CA certificate of a new host directory was not loaded")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"net/url"
 	"strings"
 
+	"github.com/Sirupsen/logrus"
 	"github.com/docker/docker/opts"
 	flag "github.com/docker/docker/pkg/mflag"
 	"github.com/docker/docker/reference"
@@ -21,12 +22,24 @@ type ServiceOptions struct {
 	// V2Only controls access to legacy registries.  If it is set to true via the
 	// command line flag the daemon will not attempt to contact v1 legacy registries
 	V2Only bool `json:"disable-legacy-registry,omitempty"`
+
+	// CredentialHelpers maps registry hosts to the credential helpers the
+	// daemon runs to authenticate pulls and pushes that come without
+	// credentials, like the ones triggered by restart policies.
+	CredentialHelpers map[string]string `json:"credential-helpers,omitempty"`
+
+	// RegistryTLS maps registry hosts to the TLS settings of the connections to
+	// them, like "min-version=1.2,server-name=registry.internal".
+	RegistryTLS map[string]string `json:"registry-tls,omitempty"`
 }
 
 // serviceConfig holds daemon configuration for the registry service.
 type serviceConfig struct {
 	registrytypes.ServiceConfig
 	V2Only bool
+
+	// CredentialHelpers maps normalized registry hosts to credential helpers.
+	CredentialHelpers map[string]string
 }
 
 var (
@@ -78,6 +91,16 @@ func (options *ServiceOptions) InstallCliFlags(cmd *flag.FlagSet, usageFn func(s
 	cmd.Var(insecureRegistries, []string{"-insecure-registry"}, usageFn("Enable insecure registry communication"))
 
 	cmd.BoolVar(&options.V2Only, []string{"-disable-legacy-registry"}, false, usageFn("Do not contact legacy registries"))
+
+	if options.CredentialHelpers == nil {
+		options.CredentialHelpers = make(map[string]string)
+	}
+	cmd.Var(opts.NewNamedMapOpts("credential-helpers", options.CredentialHelpers, ValidateCredentialHelper), []string{"-credential-helper"}, usageFn("Set the credential helper of a registry (host=helper)"))
+
+	if options.RegistryTLS == nil {
+		options.RegistryTLS = make(map[string]string)
+	}
+	cmd.Var(opts.NewNamedMapOpts("registry-tls", options.RegistryTLS, ValidateRegistryTLS), []string{"-registry-tls"}, usageFn("Set the TLS settings of a registry (host=key=value,...)"))
 }
 
 // newServiceConfig returns a new instance of ServiceConfig
@@ -95,9 +118,22 @@ func newServiceConfig(options ServiceOptions) *serviceConfig {
 			IndexConfigs:          make(map[string]*registrytypes.IndexInfo, 0),
 			// Hack: Bypass setting the mirrors to IndexConfigs since they are going away
 			// and Mirrors are only for the official registry anyways.
-			Mirrors: options.Mirrors,
+			Mirrors:    options.Mirrors,
+			TLSConfigs: make(map[string]*registrytypes.TLSConfig),
 		},
-		V2Only: options.V2Only,
+		V2Only:            options.V2Only,
+		CredentialHelpers: make(map[string]string),
+	}
+	for host, helper := range options.CredentialHelpers {
+		config.CredentialHelpers[normalizeCredentialHelperHost(host)] = helper
+	}
+	for host, val := range options.RegistryTLS {
+		settings, err := ParseTLSSettings(val)
+		if err != nil {
+			logrus.Warnf("Ignoring the TLS settings of %s: %v", host, err)
+			continue
+		}
+		config.TLSConfigs[normalizeCredentialHelperHost(host)] = settings
 	}
 	// Split --insecure-registry into CIDR and registry-specific settings.
 	for _, r := range options.InsecureRegistries {
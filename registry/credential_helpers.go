@@ -0,0 +1,124 @@
+package registry
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/docker/engine-api/types"
+	registrytypes "github.com/docker/engine-api/types/registry"
+)
+
+const (
+	// credentialHelperPrefix is the prefix of the credential helper binaries,
+	// the same ones the client uses as credential stores.
+	credentialHelperPrefix = "docker-credential-"
+	// credentialHelperTokenUsername is the user name returned by helpers
+	// along with an identity token.
+	credentialHelperTokenUsername = "<token>"
+	// errCredentialHelperNotFound is the output of helpers that have no
+	// credentials for a registry.
+	errCredentialHelperNotFound = "credentials not found in native keychain"
+)
+
+// for mocking in unit tests
+var runCredentialHelper = func(helper, serverURL string) ([]byte, error) {
+	cmd := exec.Command(credentialHelperPrefix+helper, "get")
+	cmd.Stdin = strings.NewReader(serverURL)
+	return cmd.Output()
+}
+
+// credentialHelperResponse is the output of a credential helper.
+type credentialHelperResponse struct {
+	Username string
+	Secret   string
+}
+
+// ValidateCredentialHelper validates a host=helper pair of the
+// --credential-helper flag.
+func ValidateCredentialHelper(val string) (string, error) {
+	parts := strings.SplitN(val, "=", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", fmt.Errorf("invalid credential helper %q, expected host=helper", val)
+	}
+	if strings.ContainsAny(parts[1], `/\`) {
+		return "", fmt.Errorf("invalid credential helper %q, expected the name of a %s* binary", parts[1], credentialHelperPrefix)
+	}
+	return val, nil
+}
+
+// normalizeCredentialHelperHost strips the scheme and path of a registry
+// address, and maps the addresses of the official registry to its index name.
+func normalizeCredentialHelperHost(host string) string {
+	host = strings.TrimPrefix(strings.TrimPrefix(host, "https://"), "http://")
+	host = strings.SplitN(host, "/", 2)[0]
+	switch host {
+	case DefaultV1Registry.Host, DefaultV2Registry.Host:
+		return IndexName
+	}
+	return host
+}
+
+// CredentialsFromHelper returns the credentials of a registry from the
+// credential helper configured for it, or nil if there is no helper or the
+// helper has no credentials for the registry.
+func (s *Service) CredentialsFromHelper(index *registrytypes.IndexInfo) (*types.AuthConfig, error) {
+	helper, ok := s.config.CredentialHelpers[normalizeCredentialHelperHost(index.Name)]
+	if !ok {
+		return nil, nil
+	}
+	if strings.ContainsAny(helper, `/\`) {
+		return nil, fmt.Errorf("invalid credential helper %q for %s", helper, index.Name)
+	}
+
+	serverURL := GetAuthConfigKey(index)
+	out, err := runCredentialHelper(helper, serverURL)
+	if err != nil {
+		t := strings.TrimSpace(string(out))
+		if exitErr, ok := err.(*exec.ExitError); ok && t == "" {
+			t = strings.TrimSpace(string(exitErr.Stderr))
+		}
+		if t == errCredentialHelperNotFound {
+			return nil, nil
+		}
+		logrus.Debugf("error getting credentials from %s%s - err: %v, out: `%s`", credentialHelperPrefix, helper, err, t)
+		if t == "" {
+			t = err.Error()
+		}
+		return nil, fmt.Errorf("credential helper %s failed for %s: %s", helper, index.Name, t)
+	}
+
+	var resp credentialHelperResponse
+	if err := json.NewDecoder(bytes.NewReader(out)).Decode(&resp); err != nil {
+		return nil, fmt.Errorf("credential helper %s returned invalid credentials for %s: %v", helper, index.Name, err)
+	}
+	authConfig := &types.AuthConfig{ServerAddress: serverURL}
+	if resp.Username == credentialHelperTokenUsername {
+		authConfig.IdentityToken = resp.Secret
+	} else {
+		authConfig.Username = resp.Username
+		authConfig.Password = resp.Secret
+	}
+	return authConfig, nil
+}
+
+// ResolveCredentials returns authConfig if it holds credentials, and the
+// credentials of the index from its credential helper otherwise. Helper
+// failures are logged, and the pull or push goes on anonymously.
+func (s *Service) ResolveCredentials(index *registrytypes.IndexInfo, authConfig *types.AuthConfig) *types.AuthConfig {
+	if authConfig != nil && (authConfig.Username != "" || authConfig.Auth != "" || authConfig.IdentityToken != "" || authConfig.RegistryToken != "") {
+		return authConfig
+	}
+	creds, err := s.CredentialsFromHelper(index)
+	if err != nil {
+		logrus.Warnf("Could not get the credentials of %s: %v", index.Name, err)
+		return authConfig
+	}
+	if creds == nil {
+		return authConfig
+	}
+	return creds
+}
@@ -0,0 +1,92 @@
+package registry
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/docker/engine-api/types"
+	registrytypes "github.com/docker/engine-api/types/registry"
+)
+
+func TestCredentialsFromHelper(t *testing.T) {
+	defer func(old func(string, string) ([]byte, error)) { runCredentialHelper = old }(runCredentialHelper)
+	var calls []string
+	runCredentialHelper = func(helper, serverURL string) ([]byte, error) {
+		calls = append(calls, helper+" "+serverURL)
+		switch serverURL {
+		case IndexServer:
+			return []byte(`{"Username":"hub","Secret":"hubpass"}`), nil
+		case "registry.example.com:5000":
+			return []byte(`{"Username":"<token>","Secret":"identity"}`), nil
+		}
+		return []byte(errCredentialHelperNotFound + "\n"), errors.New("exit status 1")
+	}
+
+	s := NewService(ServiceOptions{CredentialHelpers: map[string]string{
+		"https://index.docker.io/v1/": "hub",
+		"registry.example.com:5000":   "ecr",
+		"other.example.com":           "ecr",
+	}})
+
+	official := &registrytypes.IndexInfo{Name: IndexName, Official: true}
+	creds, err := s.CredentialsFromHelper(official)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if creds == nil || creds.Username != "hub" || creds.Password != "hubpass" || creds.ServerAddress != IndexServer {
+		t.Fatalf("unexpected credentials for the official registry: %+v", creds)
+	}
+
+	creds, err = s.CredentialsFromHelper(&registrytypes.IndexInfo{Name: "registry.example.com:5000"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if creds == nil || creds.Username != "" || creds.IdentityToken != "identity" {
+		t.Fatalf("expected an identity token, got %+v", creds)
+	}
+
+	creds, err = s.CredentialsFromHelper(&registrytypes.IndexInfo{Name: "other.example.com"})
+	if err != nil || creds != nil {
+		t.Fatalf("expected no credentials, got %+v, %v", creds, err)
+	}
+
+	calls = nil
+	creds, err = s.CredentialsFromHelper(&registrytypes.IndexInfo{Name: "unconfigured.example.com"})
+	if err != nil || creds != nil || len(calls) != 0 {
+		t.Fatalf("expected no helper to run, got %+v, %v, %v", creds, err, calls)
+	}
+}
+
+func TestResolveCredentials(t *testing.T) {
+	defer func(old func(string, string) ([]byte, error)) { runCredentialHelper = old }(runCredentialHelper)
+	runCredentialHelper = func(helper, serverURL string) ([]byte, error) {
+		return []byte(`{"Username":"helper","Secret":"pass"}`), nil
+	}
+
+	s := NewService(ServiceOptions{CredentialHelpers: map[string]string{"docker.io": "hub"}})
+	index := &registrytypes.IndexInfo{Name: IndexName, Official: true}
+
+	given := &types.AuthConfig{Username: "user", Password: "secret"}
+	if creds := s.ResolveCredentials(index, given); creds != given {
+		t.Fatalf("expected the given credentials, got %+v", creds)
+	}
+	if creds := s.ResolveCredentials(index, &types.AuthConfig{}); creds.Username != "helper" {
+		t.Fatalf("expected the helper credentials, got %+v", creds)
+	}
+	if creds := s.ResolveCredentials(index, nil); creds == nil || creds.Username != "helper" {
+		t.Fatalf("expected the helper credentials, got %+v", creds)
+	}
+}
+
+func TestValidateCredentialHelper(t *testing.T) {
+	for _, val := range []string{"registry.example.com=ecr-login", "docker.io=osxkeychain"} {
+		if _, err := ValidateCredentialHelper(val); err != nil {
+			t.Fatalf("%s: unexpected error %v", val, err)
+		}
+	}
+	for _, val := range []string{"registry.example.com", "=ecr-login", "docker.io=", "docker.io=../bin/sh"} {
+		if _, err := ValidateCredentialHelper(val); err == nil {
+			t.Fatalf("%s: expected an error", val)
+		}
+	}
+}
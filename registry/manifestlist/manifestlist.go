@@ -0,0 +1,106 @@
+// Package manifestlist composes manifest lists from images pushed to a
+// registry, so that a single reference serves the image of each platform.
+// The lists are composed locally, annotated with the platforms of their
+// images, and pushed once complete.
+package manifestlist
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/digest"
+	"github.com/docker/distribution/manifest/manifestlist"
+	"github.com/docker/distribution/manifest/schema1"
+	"github.com/docker/distribution/manifest/schema2"
+	"github.com/docker/docker/reference"
+	"github.com/docker/docker/registry/bundle"
+)
+
+// maxConfigSize is the size of the largest image configuration read.
+const maxConfigSize = 4 << 20
+
+// Source is a repository the images of manifest lists are read from.
+type Source interface {
+	// Name returns the name of the repository on its registry.
+	Name() string
+	// Manifest fetches a manifest by tag or digest.
+	Manifest(tagOrDigest string) (bundle.Descriptor, []byte, error)
+	// Blob fetches a blob.
+	Blob(dgst digest.Digest) (io.ReadCloser, error)
+}
+
+// Image is an image of a manifest list.
+type Image struct {
+	// Ref is the reference of the image by digest.
+	Ref string
+	// Descriptor describes the manifest of the image and its platform.
+	Descriptor manifestlist.ManifestDescriptor
+}
+
+// Inspect reads the manifest of an image, and returns it along with the
+// platform of its configuration.
+func Inspect(src Source, name reference.Named, tagOrDigest string) (Image, error) {
+	desc, payload, err := src.Manifest(tagOrDigest)
+	if err != nil {
+		return Image{}, err
+	}
+	ref, err := reference.WithDigest(name, desc.Digest)
+	if err != nil {
+		return Image{}, err
+	}
+	img := Image{
+		Ref: ref.String(),
+		Descriptor: manifestlist.ManifestDescriptor{
+			Descriptor: distribution.Descriptor{MediaType: desc.MediaType, Digest: desc.Digest, Size: desc.Size},
+		},
+	}
+
+	switch desc.MediaType {
+	case manifestlist.MediaTypeManifestList, bundle.MediaTypeOCIIndex:
+		return Image{}, fmt.Errorf("%s is a manifest list, and manifest lists cannot be nested", name.Name())
+	case schema1.MediaTypeManifest, schema1.MediaTypeSignedManifest, "application/json", "":
+		var m struct {
+			Architecture string `json:"architecture"`
+		}
+		if err := json.Unmarshal(payload, &m); err != nil {
+			return Image{}, fmt.Errorf("invalid manifest: %v", err)
+		}
+		// schema1 manifests do not record the OS of their images.
+		img.Descriptor.Platform = manifestlist.PlatformSpec{OS: "linux", Architecture: m.Architecture}
+		return img, nil
+	}
+
+	var m schema2.Manifest
+	if err := json.Unmarshal(payload, &m); err != nil {
+		return Image{}, fmt.Errorf("invalid manifest: %v", err)
+	}
+	if m.Config.Digest == "" {
+		return Image{}, fmt.Errorf("manifest %s has no image configuration", desc.Digest)
+	}
+	rc, err := src.Blob(m.Config.Digest)
+	if err != nil {
+		return Image{}, err
+	}
+	defer rc.Close()
+	data, err := ioutil.ReadAll(io.LimitReader(rc, maxConfigSize))
+	if err != nil {
+		return Image{}, err
+	}
+	var config struct {
+		OS           string `json:"os"`
+		Architecture string `json:"architecture"`
+		Variant      string `json:"variant"`
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return Image{}, fmt.Errorf("invalid image configuration: %v", err)
+	}
+	img.Descriptor.Platform = manifestlist.PlatformSpec{
+		OS:           config.OS,
+		Architecture: config.Architecture,
+		Variant:      config.Variant,
+	}
+	return img, nil
+}
@@ -0,0 +1,245 @@
+package manifestlist
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/digest"
+	"github.com/docker/distribution/manifest/manifestlist"
+	"github.com/docker/distribution/manifest/schema2"
+	"github.com/docker/docker/reference"
+	"github.com/docker/docker/registry/bundle"
+)
+
+// fakeRepository is an in-memory repository.
+type fakeRepository struct {
+	name      string
+	blobs     map[digest.Digest][]byte
+	manifests map[string]bundle.Descriptor
+	mount     bool
+	mounted   []digest.Digest
+}
+
+func newFakeRepository(name string) *fakeRepository {
+	return &fakeRepository{
+		name:      name,
+		blobs:     make(map[digest.Digest][]byte),
+		manifests: make(map[string]bundle.Descriptor),
+	}
+}
+
+func (r *fakeRepository) addBlob(mediaType string, data []byte) bundle.Descriptor {
+	desc := bundle.Descriptor{MediaType: mediaType, Digest: digest.FromBytes(data), Size: int64(len(data))}
+	r.blobs[desc.Digest] = data
+	return desc
+}
+
+// addImage adds an image of a platform, and returns its manifest.
+func (r *fakeRepository) addImage(tag, os, arch string) bundle.Descriptor {
+	config := r.addBlob(schema2.MediaTypeConfig, []byte(fmt.Sprintf(`{"os": %q, "architecture": %q}`, os, arch)))
+	layer := r.addBlob(schema2.MediaTypeLayer, []byte("layer of "+arch))
+	payload, err := json.Marshal(schema2.Manifest{
+		Versioned: schema2.SchemaVersion,
+		Config:    distribution.Descriptor{MediaType: config.MediaType, Digest: config.Digest, Size: config.Size},
+		Layers:    []distribution.Descriptor{{MediaType: layer.MediaType, Digest: layer.Digest, Size: layer.Size}},
+	})
+	if err != nil {
+		panic(err)
+	}
+	desc := r.addBlob(schema2.MediaTypeManifest, payload)
+	r.manifests[desc.Digest.String()] = desc
+	r.manifests[tag] = desc
+	return desc
+}
+
+func (r *fakeRepository) Name() string {
+	return r.name
+}
+
+func (r *fakeRepository) Manifest(tagOrDigest string) (bundle.Descriptor, []byte, error) {
+	desc, ok := r.manifests[tagOrDigest]
+	if !ok {
+		return bundle.Descriptor{}, nil, fmt.Errorf("manifest %s not found", tagOrDigest)
+	}
+	return desc, r.blobs[desc.Digest], nil
+}
+
+func (r *fakeRepository) Blob(dgst digest.Digest) (io.ReadCloser, error) {
+	data, ok := r.blobs[dgst]
+	if !ok {
+		return nil, fmt.Errorf("blob %s not found", dgst)
+	}
+	return ioutil.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (r *fakeRepository) HasBlob(dgst digest.Digest) (bool, error) {
+	_, ok := r.blobs[dgst]
+	return ok, nil
+}
+
+func (r *fakeRepository) MountBlob(dgst digest.Digest, from string) (bool, error) {
+	if r.mount {
+		r.mounted = append(r.mounted, dgst)
+		r.blobs[dgst] = nil
+	}
+	return r.mount, nil
+}
+
+func (r *fakeRepository) PutBlob(desc bundle.Descriptor, content io.Reader) error {
+	data, err := ioutil.ReadAll(content)
+	if err != nil {
+		return err
+	}
+	r.blobs[desc.Digest] = data
+	return nil
+}
+
+func (r *fakeRepository) PutManifest(tagOrDigest string, desc bundle.Descriptor, payload []byte) error {
+	r.blobs[desc.Digest] = payload
+	r.manifests[tagOrDigest] = desc
+	return nil
+}
+
+func inspect(t *testing.T, repo *fakeRepository, tag string) Image {
+	name, err := reference.WithName(repo.name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := Inspect(repo, name, tag)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return img
+}
+
+func TestInspect(t *testing.T) {
+	repo := newFakeRepository("registry.example.com/app")
+	desc := repo.addImage("arm", "linux", "arm")
+
+	img := inspect(t, repo, "arm")
+	if img.Ref != "registry.example.com/app@"+desc.Digest.String() {
+		t.Fatalf("unexpected reference %s", img.Ref)
+	}
+	if img.Descriptor.Digest != desc.Digest || img.Descriptor.MediaType != schema2.MediaTypeManifest {
+		t.Fatalf("unexpected descriptor %+v", img.Descriptor)
+	}
+	if !reflect.DeepEqual(img.Descriptor.Platform, manifestlist.PlatformSpec{OS: "linux", Architecture: "arm"}) {
+		t.Fatalf("unexpected platform %+v", img.Descriptor.Platform)
+	}
+
+	list := repo.addBlob(manifestlist.MediaTypeManifestList, []byte(`{"schemaVersion": 2, "manifests": []}`))
+	repo.manifests["list"] = list
+	name, _ := reference.WithName(repo.name)
+	if _, err := Inspect(repo, name, "list"); err == nil {
+		t.Fatal("expected manifest lists to be rejected")
+	}
+}
+
+func TestPush(t *testing.T) {
+	for _, mount := range []bool{true, false} {
+		dst := newFakeRepository("registry.example.com/app")
+		src := newFakeRepository("registry.example.com/app-arm")
+		dst.mount = mount
+		dst.addImage("amd64", "linux", "amd64")
+		armDesc := src.addImage("latest", "linux", "arm")
+		images := []Image{inspect(t, dst, "amd64"), inspect(t, src, "latest")}
+		images[1].Descriptor.Platform.Variant = "v7"
+
+		name, _ := reference.WithName(dst.name)
+		open := func(ref reference.Named) (Source, error) {
+			if ref.Name() != src.name {
+				return nil, fmt.Errorf("unexpected repository %s", ref.Name())
+			}
+			return src, nil
+		}
+		dgst, err := Push(dst, name, "latest", images, open, ioutil.Discard)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, ok := dst.manifests[armDesc.Digest.String()]; !ok {
+			t.Fatal("expected the manifest of the other repository to be copied")
+		}
+		if mount && len(dst.mounted) != 2 {
+			t.Fatalf("expected the config and layer to be mounted, got %v", dst.mounted)
+		}
+		if !mount && len(dst.blobs) != 7 {
+			t.Fatalf("expected the blobs to be copied, got %d blobs", len(dst.blobs))
+		}
+
+		desc, payload, err := dst.Manifest("latest")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if desc.Digest != dgst || desc.MediaType != manifestlist.MediaTypeManifestList {
+			t.Fatalf("unexpected list descriptor %+v", desc)
+		}
+		var list manifestlist.ManifestList
+		if err := json.Unmarshal(payload, &list); err != nil {
+			t.Fatal(err)
+		}
+		expected := []manifestlist.ManifestDescriptor{images[0].Descriptor, images[1].Descriptor}
+		if !reflect.DeepEqual(list.Manifests, expected) {
+			t.Fatalf("expected %+v, got %+v", expected, list.Manifests)
+		}
+	}
+}
+
+func TestPushRejects(t *testing.T) {
+	dst := newFakeRepository("registry.example.com/app")
+	dst.addImage("amd64", "", "amd64")
+	name, _ := reference.WithName(dst.name)
+	if _, err := Push(dst, name, "latest", []Image{inspect(t, dst, "amd64")}, nil, ioutil.Discard); err == nil {
+		t.Fatal("expected an image of unknown platform to be rejected")
+	}
+
+	other := newFakeRepository("other.example.com/app")
+	other.addImage("latest", "linux", "arm")
+	if _, err := Push(dst, name, "latest", []Image{inspect(t, other, "latest")}, nil, ioutil.Discard); err == nil {
+		t.Fatal("expected an image of another registry to be rejected")
+	}
+}
+
+func TestStore(t *testing.T) {
+	root, err := ioutil.TempDir("", "manifest-lists")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	s := NewStore(root)
+	list := "registry.example.com:5000/app:latest"
+	if s.Exists(list) {
+		t.Fatal("expected the list not to exist")
+	}
+	if _, err := s.Get(list); err == nil {
+		t.Fatal("expected an error for a missing list")
+	}
+
+	images := []Image{{Ref: "registry.example.com:5000/app@sha256:" + digest.FromBytes(nil).Hex()}}
+	images[0].Descriptor.Platform.OS = "linux"
+	if err := s.Save(list, images); err != nil {
+		t.Fatal(err)
+	}
+	stored, err := s.Get(list)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(stored, images) {
+		t.Fatalf("expected %+v, got %+v", images, stored)
+	}
+
+	if err := s.Remove(list); err != nil {
+		t.Fatal(err)
+	}
+	if s.Exists(list) {
+		t.Fatal("expected the list to be removed")
+	}
+}
@@ -0,0 +1,132 @@
+package manifestlist
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/docker/distribution/digest"
+	"github.com/docker/distribution/manifest/manifestlist"
+	"github.com/docker/distribution/manifest/schema2"
+	"github.com/docker/docker/reference"
+	"github.com/docker/docker/registry/bundle"
+)
+
+// mediaTypeForeignLayer is the media type of the layers that are not
+// distributed by registries, like the base layers of Windows images.
+const mediaTypeForeignLayer = "application/vnd.docker.image.rootfs.foreign.diff.tar.gzip"
+
+// Target is the repository a manifest list is pushed to.
+type Target interface {
+	bundle.Target
+	// MountBlob mounts a blob of another repository of the registry, and
+	// returns whether the registry mounted it.
+	MountBlob(dgst digest.Digest, from string) (bool, error)
+}
+
+// Push pushes a manifest list to a repository under a tag, and returns its
+// digest. The manifests of a list must be in the repository of the list, so
+// the images of the other repositories of the registry are copied first,
+// opened with open. Progress messages are written to out.
+func Push(dst Target, name reference.Named, tag string, images []Image, open func(reference.Named) (Source, error), out io.Writer) (digest.Digest, error) {
+	if len(images) == 0 {
+		return "", fmt.Errorf("manifest list %s has no images", name.Name())
+	}
+	descriptors := make([]manifestlist.ManifestDescriptor, 0, len(images))
+	for _, img := range images {
+		if img.Descriptor.Platform.OS == "" || img.Descriptor.Platform.Architecture == "" {
+			return "", fmt.Errorf("the platform of %s is unknown, it must be set with docker manifest annotate", img.Ref)
+		}
+		ref, err := reference.ParseNamed(img.Ref)
+		if err != nil {
+			return "", err
+		}
+		if ref.Name() != name.Name() {
+			if ref.Hostname() != name.Hostname() {
+				return "", fmt.Errorf("%s is not on the registry of %s, images can only be copied within a registry", img.Ref, name.Name())
+			}
+			src, err := open(ref)
+			if err != nil {
+				return "", err
+			}
+			fmt.Fprintf(out, "Copying %s\n", img.Ref)
+			if err := copyImage(src, dst, img, out); err != nil {
+				return "", err
+			}
+		}
+		descriptors = append(descriptors, img.Descriptor)
+	}
+
+	list, err := manifestlist.FromDescriptors(descriptors)
+	if err != nil {
+		return "", err
+	}
+	mediaType, payload, err := list.Payload()
+	if err != nil {
+		return "", err
+	}
+	desc := bundle.Descriptor{MediaType: mediaType, Digest: digest.FromBytes(payload), Size: int64(len(payload))}
+	if err := dst.PutManifest(tag, desc, payload); err != nil {
+		return "", err
+	}
+	fmt.Fprintf(out, "%s: pushed %s\n", tag, desc.Digest)
+	return desc.Digest, nil
+}
+
+// copyImage copies the manifest of an image and its blobs to the repository
+// of a list.
+func copyImage(src Source, dst Target, img Image, out io.Writer) error {
+	desc, payload, err := src.Manifest(img.Descriptor.Digest.String())
+	if err != nil {
+		return err
+	}
+	if desc.MediaType != schema2.MediaTypeManifest && desc.MediaType != bundle.MediaTypeOCIManifest {
+		// schema1 manifests are signed for their repository.
+		return fmt.Errorf("%s has a %s manifest, which cannot be copied to another repository, the image must be pushed to the repository of the list", img.Ref, desc.MediaType)
+	}
+	var m schema2.Manifest
+	if err := json.Unmarshal(payload, &m); err != nil {
+		return fmt.Errorf("invalid manifest: %v", err)
+	}
+
+	blobs := []bundle.Descriptor{{MediaType: m.Config.MediaType, Digest: m.Config.Digest, Size: m.Config.Size}}
+	for _, layer := range m.Layers {
+		if layer.MediaType != mediaTypeForeignLayer {
+			blobs = append(blobs, bundle.Descriptor{MediaType: layer.MediaType, Digest: layer.Digest, Size: layer.Size})
+		}
+	}
+	for _, blob := range blobs {
+		if err := copyBlob(src, dst, blob, out); err != nil {
+			return err
+		}
+	}
+	return dst.PutManifest(desc.Digest.String(), desc, payload)
+}
+
+// copyBlob copies a blob to the repository of a list, unless it holds it,
+// mounting it when the registry allows it.
+func copyBlob(src Source, dst Target, blob bundle.Descriptor, out io.Writer) error {
+	exists, err := dst.HasBlob(blob.Digest)
+	if err != nil {
+		return err
+	}
+	if exists {
+		fmt.Fprintf(out, "%s: already exists\n", blob.Digest.Hex()[:12])
+		return nil
+	}
+	mounted, err := dst.MountBlob(blob.Digest, src.Name())
+	if err != nil {
+		return err
+	}
+	if mounted {
+		fmt.Fprintf(out, "%s: mounted from %s\n", blob.Digest.Hex()[:12], src.Name())
+		return nil
+	}
+	rc, err := src.Blob(blob.Digest)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	fmt.Fprintf(out, "%s: pushing %d bytes\n", blob.Digest.Hex()[:12], blob.Size)
+	return dst.PutBlob(blob, rc)
+}
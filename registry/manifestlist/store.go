@@ -0,0 +1,79 @@
+package manifestlist
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+)
+
+// Store keeps the manifest lists being composed, until they are pushed.
+type Store struct {
+	root string
+}
+
+// NewStore creates a store keeping the lists in root.
+func NewStore(root string) *Store {
+	return &Store{root: root}
+}
+
+func (s *Store) path(list string) string {
+	return filepath.Join(s.root, url.QueryEscape(list)+".json")
+}
+
+// Get returns the images of a list.
+func (s *Store) Get(list string) ([]Image, error) {
+	data, err := ioutil.ReadFile(s.path(list))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("no manifest list %s, it must be created with docker manifest create", list)
+		}
+		return nil, err
+	}
+	var images []Image
+	if err := json.Unmarshal(data, &images); err != nil {
+		return nil, fmt.Errorf("invalid manifest list %s: %v", list, err)
+	}
+	return images, nil
+}
+
+// Exists returns whether a list is being composed.
+func (s *Store) Exists(list string) bool {
+	_, err := os.Stat(s.path(list))
+	return err == nil
+}
+
+// Save stores the images of a list.
+func (s *Store) Save(list string, images []Image) error {
+	data, err := json.MarshalIndent(images, "", "\t")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(s.root, 0700); err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(s.root, ".list")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path(list))
+}
+
+// Remove removes a list.
+func (s *Store) Remove(list string) error {
+	if err := os.Remove(s.path(list)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
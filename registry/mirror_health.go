@@ -0,0 +1,282 @@
+package registry
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	registrytypes "github.com/docker/engine-api/types/registry"
+)
+
+const (
+	// defaultMirrorCheckInterval is the time between two probes of a mirror.
+	defaultMirrorCheckInterval = 30 * time.Second
+	// mirrorProbeTimeout limits the time a mirror has to answer a probe.
+	mirrorProbeTimeout = 10 * time.Second
+	// maxMirrorPullFailures is the number of consecutive failed pulls after
+	// which a mirror is skipped, until a probe succeeds.
+	maxMirrorPullFailures = 3
+	// mirrorStatsWeight is the weight of the last outcome in the moving
+	// averages of the latency and error rate.
+	mirrorStatsWeight = 0.2
+)
+
+// mirrorStats tracks the health of a mirror.
+type mirrorStats struct {
+	healthy      bool
+	lastCheck    time.Time
+	latency      time.Duration
+	errorRate    float64
+	lastError    string
+	pullFailures int
+}
+
+// mirrorMonitor probes the registry mirrors and records the outcome of the
+// pulls going through them, so that dead mirrors are skipped and the
+// fastest ones are tried first.
+type mirrorMonitor struct {
+	mu    sync.Mutex
+	stats map[string]*mirrorStats
+}
+
+func newMirrorMonitor() *mirrorMonitor {
+	return &mirrorMonitor{stats: make(map[string]*mirrorStats)}
+}
+
+// get returns the stats of a mirror, creating them if needed. Mirrors are
+// considered healthy until they fail.
+func (m *mirrorMonitor) get(mirror string) *mirrorStats {
+	stats, ok := m.stats[mirror]
+	if !ok {
+		stats = &mirrorStats{healthy: true}
+		m.stats[mirror] = stats
+	}
+	return stats
+}
+
+// recordProbe updates the health of a mirror with the outcome of a probe.
+func (m *mirrorMonitor) recordProbe(mirror string, latency time.Duration, err error, now time.Time) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stats := m.get(mirror)
+	stats.lastCheck = now
+	if err != nil {
+		if stats.healthy {
+			logrus.Warnf("Registry mirror %s is unhealthy: %v", mirror, err)
+		}
+		stats.healthy = false
+		stats.lastError = err.Error()
+		stats.errorRate += mirrorStatsWeight * (1 - stats.errorRate)
+		return
+	}
+	if !stats.healthy {
+		logrus.Infof("Registry mirror %s is healthy again", mirror)
+	}
+	stats.healthy = true
+	stats.pullFailures = 0
+	stats.errorRate -= mirrorStatsWeight * stats.errorRate
+	if stats.latency == 0 {
+		stats.latency = latency
+	} else {
+		stats.latency += time.Duration(mirrorStatsWeight * float64(latency-stats.latency))
+	}
+}
+
+// recordPull updates the health of a mirror with the outcome of a pull.
+func (m *mirrorMonitor) recordPull(mirror string, err error) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stats := m.get(mirror)
+	if err == nil {
+		stats.pullFailures = 0
+		stats.errorRate -= mirrorStatsWeight * stats.errorRate
+		return
+	}
+	stats.pullFailures++
+	stats.lastError = err.Error()
+	stats.errorRate += mirrorStatsWeight * (1 - stats.errorRate)
+	if stats.healthy && stats.pullFailures >= maxMirrorPullFailures {
+		logrus.Warnf("Registry mirror %s is unhealthy after %d failed pulls: %v", mirror, stats.pullFailures, err)
+		stats.healthy = false
+	}
+}
+
+// order returns the healthy mirrors, the fastest ones first. Mirrors that
+// were never probed keep their configuration order, after the others.
+func (m *mirrorMonitor) order(mirrors []string) []string {
+	if m == nil {
+		return mirrors
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var ordered []string
+	for _, mirror := range mirrors {
+		if stats, ok := m.stats[mirror]; !ok || stats.healthy {
+			ordered = append(ordered, mirror)
+		}
+	}
+	sort.Stable(byLatency{ordered, m.stats})
+	return ordered
+}
+
+// health returns the health of the mirrors, in configuration order.
+func (m *mirrorMonitor) health(mirrors []string) []registrytypes.MirrorHealth {
+	health := make([]registrytypes.MirrorHealth, 0, len(mirrors))
+	for _, mirror := range mirrors {
+		h := registrytypes.MirrorHealth{URL: mirror, Healthy: true}
+		if stats, ok := m.lookup(mirror); ok {
+			h.Healthy = stats.healthy
+			h.LastCheck = stats.lastCheck
+			h.Latency = stats.latency
+			h.ErrorRate = stats.errorRate
+			h.LastError = stats.lastError
+		}
+		health = append(health, h)
+	}
+	return health
+}
+
+// lookup returns a copy of the stats of a mirror, if it has any.
+func (m *mirrorMonitor) lookup(mirror string) (mirrorStats, bool) {
+	if m == nil {
+		return mirrorStats{}, false
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stats, ok := m.stats[mirror]
+	if !ok {
+		return mirrorStats{}, false
+	}
+	return *stats, true
+}
+
+// byLatency sorts mirrors by probe latency, unprobed mirrors last.
+type byLatency struct {
+	mirrors []string
+	stats   map[string]*mirrorStats
+}
+
+func (s byLatency) Len() int      { return len(s.mirrors) }
+func (s byLatency) Swap(i, j int) { s.mirrors[i], s.mirrors[j] = s.mirrors[j], s.mirrors[i] }
+func (s byLatency) Less(i, j int) bool {
+	li, lj := s.latency(i), s.latency(j)
+	if lj == 0 {
+		return li != 0
+	}
+	return li != 0 && li < lj
+}
+
+func (s byLatency) latency(i int) time.Duration {
+	if stats, ok := s.stats[s.mirrors[i]]; ok {
+		return stats.latency
+	}
+	return 0
+}
+
+// StartMirrorHealthChecks probes the registry mirrors in the background,
+// until the returned function is called. Mirrors failing their probes are
+// skipped by pulls until they recover.
+func (s *Service) StartMirrorHealthChecks() (stop func()) {
+	done := make(chan struct{})
+	if len(s.config.Mirrors) == 0 {
+		return func() {}
+	}
+	go func() {
+		ticker := time.NewTicker(defaultMirrorCheckInterval)
+		defer ticker.Stop()
+		for {
+			s.probeMirrors()
+			select {
+			case <-ticker.C:
+			case <-done:
+				return
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(done) })
+	}
+}
+
+// probeMirrors probes all the mirrors concurrently.
+func (s *Service) probeMirrors() {
+	var wg sync.WaitGroup
+	for _, mirror := range s.config.Mirrors {
+		wg.Add(1)
+		go func(mirror string) {
+			defer wg.Done()
+			start := time.Now()
+			err := s.probeMirror(mirror)
+			s.mirrors.recordProbe(mirror, time.Since(start), err, time.Now())
+		}(mirror)
+	}
+	wg.Wait()
+}
+
+// probeMirror checks that a mirror answers on the base of the v2 API.
+// Authentication challenges are fine, server errors are not.
+func (s *Service) probeMirror(mirror string) error {
+	mirrorURL, err := mirrorURL(mirror)
+	if err != nil {
+		return err
+	}
+	tlsConfig, err := s.tlsConfigForMirror(mirrorURL)
+	if err != nil {
+		return err
+	}
+	client := HTTPClient(NewTransport(tlsConfig))
+	client.Timeout = mirrorProbeTimeout
+
+	resp, err := client.Get(strings.TrimSuffix(mirrorURL.String(), "/") + "/v2/")
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return fmt.Errorf("probe returned %s", resp.Status)
+	}
+	return nil
+}
+
+// ReportMirrorPull records the outcome of a pull from a mirror endpoint.
+// Mirrors failing repeatedly are skipped by the next pulls until a probe
+// succeeds.
+func (s *Service) ReportMirrorPull(endpoint APIEndpoint, err error) {
+	if !endpoint.Mirror {
+		return
+	}
+	for _, mirror := range s.config.Mirrors {
+		if u, parseErr := mirrorURL(mirror); parseErr == nil && u.String() == endpoint.URL.String() {
+			s.mirrors.recordPull(mirror, err)
+			return
+		}
+	}
+}
+
+// MirrorHealth returns the health of the registry mirrors.
+func (s *Service) MirrorHealth() []registrytypes.MirrorHealth {
+	if len(s.config.Mirrors) == 0 {
+		return nil
+	}
+	return s.mirrors.health(s.config.Mirrors)
+}
+
+// mirrorURL parses the address of a mirror, https being the default scheme.
+func mirrorURL(mirror string) (*url.URL, error) {
+	if !strings.HasPrefix(mirror, "http://") && !strings.HasPrefix(mirror, "https://") {
+		mirror = "https://" + mirror
+	}
+	return url.Parse(mirror)
+}
@@ -0,0 +1,89 @@
+package registry
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMirrorMonitorOrder(t *testing.T) {
+	m := newMirrorMonitor()
+	mirrors := []string{"https://slow/", "https://dead/", "https://fast/", "https://new/"}
+	now := time.Now()
+	m.recordProbe("https://slow/", 300*time.Millisecond, nil, now)
+	m.recordProbe("https://dead/", time.Second, errors.New("connection refused"), now)
+	m.recordProbe("https://fast/", 20*time.Millisecond, nil, now)
+
+	expected := []string{"https://fast/", "https://slow/", "https://new/"}
+	if ordered := m.order(mirrors); !reflect.DeepEqual(ordered, expected) {
+		t.Fatalf("expected %v, got %v", expected, ordered)
+	}
+
+	// Repeated pull failures make a mirror unhealthy until a probe succeeds.
+	for i := 0; i < maxMirrorPullFailures; i++ {
+		m.recordPull("https://fast/", errors.New("unexpected EOF"))
+	}
+	expected = []string{"https://slow/", "https://new/"}
+	if ordered := m.order(mirrors); !reflect.DeepEqual(ordered, expected) {
+		t.Fatalf("expected %v, got %v", expected, ordered)
+	}
+	m.recordProbe("https://fast/", 20*time.Millisecond, nil, now)
+	m.recordProbe("https://dead/", 10*time.Millisecond, nil, now)
+	expected = []string{"https://dead/", "https://fast/", "https://slow/", "https://new/"}
+	if ordered := m.order(mirrors); !reflect.DeepEqual(ordered, expected) {
+		t.Fatalf("expected %v, got %v", expected, ordered)
+	}
+
+	health := m.health(mirrors)
+	if len(health) != 4 || !health[3].Healthy || !health[3].LastCheck.IsZero() {
+		t.Fatalf("unexpected health %+v", health)
+	}
+	if health[1].ErrorRate <= 0 || health[1].LastError != "connection refused" {
+		t.Fatalf("expected the dead mirror to keep its error stats, got %+v", health[1])
+	}
+}
+
+func TestProbeMirrors(t *testing.T) {
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v2/" {
+			t.Errorf("unexpected probe of %s", r.URL.Path)
+		}
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer up.Close()
+	broken := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer broken.Close()
+
+	s := NewService(ServiceOptions{Mirrors: []string{up.URL + "/", broken.URL + "/"}})
+	s.probeMirrors()
+
+	health := s.MirrorHealth()
+	if len(health) != 2 {
+		t.Fatalf("expected the health of 2 mirrors, got %+v", health)
+	}
+	if !health[0].Healthy || health[0].LastCheck.IsZero() {
+		t.Fatalf("expected %s to be healthy, got %+v", up.URL, health[0])
+	}
+	if health[1].Healthy {
+		t.Fatalf("expected %s to be unhealthy, got %+v", broken.URL, health[1])
+	}
+
+	endpoints, err := s.LookupPullEndpoints(IndexName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var mirrors []string
+	for _, endpoint := range endpoints {
+		if endpoint.Mirror {
+			mirrors = append(mirrors, endpoint.URL.String())
+		}
+	}
+	if !reflect.DeepEqual(mirrors, []string{up.URL + "/"}) {
+		t.Fatalf("expected only the healthy mirror to be used, got %v", mirrors)
+	}
+}
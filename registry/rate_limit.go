@@ -0,0 +1,169 @@
+package registry
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+)
+
+const (
+	// maxRateLimitRetries is the number of times a request rejected with
+	// 429 Too Many Requests is retried.
+	maxRateLimitRetries = 3
+	// maxRetryAfter is the longest Retry-After delay waited for. Requests
+	// asked to wait longer fail with the 429 response of the registry.
+	maxRetryAfter = time.Minute
+	// defaultRetryAfter is the delay before the first retry of a request
+	// rejected without a Retry-After header, doubled on every retry.
+	defaultRetryAfter = time.Second
+)
+
+// errRateLimitCanceled is returned when a request is canceled while waiting
+// for the Retry-After delay of the registry.
+var errRateLimitCanceled = errors.New("net/http: request canceled while waiting to retry")
+
+// for mocking in unit tests
+var rateLimitWait = func(d time.Duration, cancel <-chan struct{}) bool {
+	select {
+	case <-time.After(d):
+		return true
+	case <-cancel:
+		return false
+	}
+}
+
+// RateLimit is the request quota a registry reports in the
+// RateLimit-Limit and RateLimit-Remaining headers of its responses, like
+// Docker Hub does for pulls.
+type RateLimit struct {
+	// Limit is the number of requests allowed in the window.
+	Limit int
+	// Remaining is the number of requests left in the window.
+	Remaining int
+	// Window is the length of the quota window, if the registry reports it.
+	Window time.Duration
+	// RetryAfter is set when the registry rejected a request with 429 Too
+	// Many Requests, to the delay before the request is retried.
+	RetryAfter time.Duration
+}
+
+// ParseRateLimit parses the rate limit headers of a response, written like
+// "100;w=21600". It returns false if the response has no rate limit headers.
+func ParseRateLimit(h http.Header) (RateLimit, bool) {
+	limit, window, ok := parseRateLimitHeader(h.Get("RateLimit-Limit"))
+	if !ok {
+		return RateLimit{}, false
+	}
+	remaining, _, ok := parseRateLimitHeader(h.Get("RateLimit-Remaining"))
+	if !ok {
+		return RateLimit{}, false
+	}
+	return RateLimit{Limit: limit, Remaining: remaining, Window: window}, true
+}
+
+func parseRateLimitHeader(val string) (int, time.Duration, bool) {
+	if val == "" {
+		return 0, 0, false
+	}
+	fields := strings.Split(val, ";")
+	n, err := strconv.Atoi(strings.TrimSpace(fields[0]))
+	if err != nil || n < 0 {
+		return 0, 0, false
+	}
+	var window time.Duration
+	for _, field := range fields[1:] {
+		field = strings.TrimSpace(field)
+		if strings.HasPrefix(field, "w=") {
+			if seconds, err := strconv.Atoi(field[2:]); err == nil && seconds > 0 {
+				window = time.Duration(seconds) * time.Second
+			}
+		}
+	}
+	return n, window, true
+}
+
+// parseRetryAfter parses a Retry-After header, which holds either a number
+// of seconds or an HTTP date.
+func parseRetryAfter(val string, now time.Time) (time.Duration, bool) {
+	if val == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(val); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	date, err := http.ParseTime(val)
+	if err != nil {
+		return 0, false
+	}
+	if d := date.Sub(now); d > 0 {
+		return d, true
+	}
+	return 0, true
+}
+
+// RateLimitTransport retries the requests a registry rejects with 429 Too
+// Many Requests, after the delay of their Retry-After header, and reports
+// the rate limit headers of the responses to Observe.
+type RateLimitTransport struct {
+	// Base is the transport the requests are sent with.
+	Base http.RoundTripper
+	// Observe, if set, is called with the rate limit of every response that
+	// reports one, and before every retry.
+	Observe func(RateLimit)
+}
+
+// RoundTrip sends a request, retrying it while it is rate limited. Requests
+// with a body cannot be replayed, so they are never retried.
+func (t *RateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		resp, err := t.Base.RoundTrip(req)
+		if err != nil {
+			return nil, err
+		}
+		limit, ok := ParseRateLimit(resp.Header)
+		if resp.StatusCode != http.StatusTooManyRequests {
+			if ok && t.Observe != nil {
+				t.Observe(limit)
+			}
+			return resp, nil
+		}
+
+		delay, ok := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+		if !ok {
+			delay = defaultRetryAfter << uint(attempt)
+		}
+		if attempt >= maxRateLimitRetries || req.Body != nil || delay > maxRetryAfter {
+			return resp, nil
+		}
+		logrus.Debugf("Request to %s rate limited, retrying in %s", req.URL, delay)
+		limit.RetryAfter = delay
+		if t.Observe != nil {
+			t.Observe(limit)
+		}
+		io.Copy(ioutil.Discard, resp.Body)
+		resp.Body.Close()
+		if !rateLimitWait(delay, req.Cancel) {
+			return nil, errRateLimitCanceled
+		}
+	}
+}
+
+// CancelRequest cancels an in-flight request by forwarding the cancellation
+// to the base transport, if it supports it.
+func (t *RateLimitTransport) CancelRequest(req *http.Request) {
+	type canceler interface {
+		CancelRequest(*http.Request)
+	}
+	if cr, ok := t.Base.(canceler); ok {
+		cr.CancelRequest(req)
+	}
+}
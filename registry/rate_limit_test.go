@@ -0,0 +1,105 @@
+package registry
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseRateLimit(t *testing.T) {
+	h := http.Header{}
+	if _, ok := ParseRateLimit(h); ok {
+		t.Fatal("expected no rate limit without headers")
+	}
+	h.Set("RateLimit-Limit", "100;w=21600")
+	h.Set("RateLimit-Remaining", "76;w=21600")
+	limit, ok := ParseRateLimit(h)
+	if !ok {
+		t.Fatal("expected a rate limit")
+	}
+	if limit != (RateLimit{Limit: 100, Remaining: 76, Window: 6 * time.Hour}) {
+		t.Fatalf("unexpected rate limit %+v", limit)
+	}
+	h.Set("RateLimit-Remaining", "many")
+	if _, ok := ParseRateLimit(h); ok {
+		t.Fatal("expected an invalid header to be ignored")
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2016, 4, 1, 12, 0, 0, 0, time.UTC)
+	for val, expected := range map[string]time.Duration{
+		"120":                           2 * time.Minute,
+		"Fri, 01 Apr 2016 12:00:30 GMT": 30 * time.Second,
+		"Fri, 01 Apr 2016 11:00:00 GMT": 0,
+	} {
+		d, ok := parseRetryAfter(val, now)
+		if !ok || d != expected {
+			t.Fatalf("expected %s for %q, got %s", expected, val, d)
+		}
+	}
+	for _, val := range []string{"", "-1", "soon"} {
+		if _, ok := parseRetryAfter(val, now); ok {
+			t.Fatalf("expected %q to be rejected", val)
+		}
+	}
+}
+
+func TestRateLimitTransport(t *testing.T) {
+	defer func(old func(time.Duration, <-chan struct{}) bool) { rateLimitWait = old }(rateLimitWait)
+	var waits []time.Duration
+	rateLimitWait = func(d time.Duration, cancel <-chan struct{}) bool {
+		waits = append(waits, d)
+		return true
+	}
+
+	rejections := 2
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("RateLimit-Limit", "100;w=21600")
+		if rejections > 0 {
+			rejections--
+			w.Header().Set("RateLimit-Remaining", "0;w=21600")
+			if rejections == 0 {
+				w.Header().Set("Retry-After", "5")
+			}
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Header().Set("RateLimit-Remaining", "99;w=21600")
+	}))
+	defer server.Close()
+
+	var observed []RateLimit
+	client := &http.Client{Transport: &RateLimitTransport{
+		Base:    http.DefaultTransport,
+		Observe: func(limit RateLimit) { observed = append(observed, limit) },
+	}}
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected the request to be retried until it succeeds, got %d", resp.StatusCode)
+	}
+	if len(waits) != 2 || waits[0] != defaultRetryAfter || waits[1] != 5*time.Second {
+		t.Fatalf("unexpected delays %v", waits)
+	}
+	if len(observed) != 3 || observed[0].RetryAfter != defaultRetryAfter || observed[2] != (RateLimit{Limit: 100, Remaining: 99, Window: 6 * time.Hour}) {
+		t.Fatalf("unexpected rate limits %+v", observed)
+	}
+
+	// Requests with a body cannot be replayed.
+	waits = nil
+	rejections = 1
+	resp, err = client.Post(server.URL, "text/plain", strings.NewReader("body"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusTooManyRequests || len(waits) != 0 {
+		t.Fatalf("expected the request not to be retried, got %d after %v", resp.StatusCode, waits)
+	}
+}
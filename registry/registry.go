@@ -34,7 +34,7 @@ func newTLSConfig(hostname string, isSecure bool) (*tls.Config, error) {
 	if isSecure && CertsDir != "" {
 		hostDir := filepath.Join(CertsDir, cleanPath(hostname))
 		logrus.Debugf("hostDir: %s", hostDir)
-		if err := ReadCertsDirectory(&tlsConfig, hostDir); err != nil {
+		if err := registryCerts.load(&tlsConfig, hostDir); err != nil {
 			return nil, err
 		}
 	}
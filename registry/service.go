@@ -16,14 +16,16 @@ import (
 // Service is a registry service. It tracks configuration data such as a list
 // of mirrors.
 type Service struct {
-	config *serviceConfig
+	config  *serviceConfig
+	mirrors *mirrorMonitor
 }
 
 // NewService returns a new instance of Service ready to be
 // installed into an engine.
 func NewService(options ServiceOptions) *Service {
 	return &Service{
-		config: newServiceConfig(options),
+		config:  newServiceConfig(options),
+		mirrors: newMirrorMonitor(),
 	}
 }
 
@@ -152,8 +154,14 @@ func (e APIEndpoint) ToV1Endpoint(userAgent string, metaHeaders http.Header) (*V
 }
 
 // TLSConfig constructs a client TLS configuration based on server defaults
+// and the certificates of the host, with the TLS settings of the host on top
 func (s *Service) TLSConfig(hostname string) (*tls.Config, error) {
-	return newTLSConfig(hostname, isSecureIndex(s.config, hostname))
+	tlsConfig, err := newTLSConfig(hostname, isSecureIndex(s.config, hostname))
+	if err != nil {
+		return nil, err
+	}
+	s.config.applyTLSSettings(tlsConfig, hostname)
+	return tlsConfig, nil
 }
 
 func (s *Service) tlsConfigForMirror(mirrorURL *url.URL) (*tls.Config, error) {
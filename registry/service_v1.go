@@ -9,6 +9,7 @@ import (
 func (s *Service) lookupV1Endpoints(hostname string) (endpoints []APIEndpoint, err error) {
 	var cfg = tlsconfig.ServerDefault
 	tlsConfig := &cfg
+	s.config.applyTLSSettings(tlsConfig, IndexName)
 	if hostname == DefaultNamespace {
 		endpoints = append(endpoints, APIEndpoint{
 			URL:          DefaultV1Registry,
@@ -37,7 +38,7 @@ func (s *Service) lookupV1Endpoints(hostname string) (endpoints []APIEndpoint, e
 		},
 	}
 
-	if tlsConfig.InsecureSkipVerify {
+	if !isSecureIndex(s.config, hostname) {
 		endpoints = append(endpoints, APIEndpoint{ // or this
 			URL: &url.URL{
 				Scheme: "http",
@@ -2,7 +2,6 @@ package registry
 
 import (
 	"net/url"
-	"strings"
 
 	"github.com/docker/go-connections/tlsconfig"
 )
@@ -10,13 +9,11 @@ import (
 func (s *Service) lookupV2Endpoints(hostname string) (endpoints []APIEndpoint, err error) {
 	var cfg = tlsconfig.ServerDefault
 	tlsConfig := &cfg
+	s.config.applyTLSSettings(tlsConfig, IndexName)
 	if hostname == DefaultNamespace || hostname == DefaultV1Registry.Host {
 		// v2 mirrors
-		for _, mirror := range s.config.Mirrors {
-			if !strings.HasPrefix(mirror, "http://") && !strings.HasPrefix(mirror, "https://") {
-				mirror = "https://" + mirror
-			}
-			mirrorURL, err := url.Parse(mirror)
+		for _, mirror := range s.mirrors.order(s.config.Mirrors) {
+			mirrorURL, err := mirrorURL(mirror)
 			if err != nil {
 				return nil, err
 			}
@@ -62,7 +59,7 @@ func (s *Service) lookupV2Endpoints(hostname string) (endpoints []APIEndpoint, e
 		},
 	}
 
-	if tlsConfig.InsecureSkipVerify {
+	if !isSecureIndex(s.config, hostname) {
 		endpoints = append(endpoints, APIEndpoint{
 			URL: &url.URL{
 				Scheme: "http",
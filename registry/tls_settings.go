@@ -0,0 +1,132 @@
+package registry
+
+import (
+	"crypto/tls"
+	"fmt"
+	"strconv"
+	"strings"
+
+	registrytypes "github.com/docker/engine-api/types/registry"
+)
+
+// tlsVersions maps the versions accepted by the min-version setting to their
+// protocol versions.
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+}
+
+// tlsCipherSuites maps the names accepted by the cipher-suites setting to
+// their identifiers. The RC4 suites are left out on purpose.
+var tlsCipherSuites = map[string]uint16{
+	"TLS_RSA_WITH_3DES_EDE_CBC_SHA":           tls.TLS_RSA_WITH_3DES_EDE_CBC_SHA,
+	"TLS_RSA_WITH_AES_128_CBC_SHA":            tls.TLS_RSA_WITH_AES_128_CBC_SHA,
+	"TLS_RSA_WITH_AES_256_CBC_SHA":            tls.TLS_RSA_WITH_AES_256_CBC_SHA,
+	"TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA":    tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA,
+	"TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA":    tls.TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA,
+	"TLS_ECDHE_RSA_WITH_3DES_EDE_CBC_SHA":     tls.TLS_ECDHE_RSA_WITH_3DES_EDE_CBC_SHA,
+	"TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA":      tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA,
+	"TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA":      tls.TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA,
+	"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256":   tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+	"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256": tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+	"TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384":   tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+	"TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384": tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+}
+
+// ParseTLSSettings parses the TLS settings of a registry, written as comma
+// separated key=value pairs, like
+// "min-version=1.2,cipher-suites=TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256:TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384".
+func ParseTLSSettings(val string) (*registrytypes.TLSConfig, error) {
+	settings := &registrytypes.TLSConfig{}
+	for _, field := range strings.Split(val, ",") {
+		parts := strings.SplitN(strings.TrimSpace(field), "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid registry TLS setting %q, expected key=value", field)
+		}
+		key, value := parts[0], parts[1]
+		switch key {
+		case "min-version":
+			if _, ok := tlsVersions[value]; !ok {
+				return nil, fmt.Errorf("invalid registry TLS setting %s: unsupported TLS version %q, expected 1.0, 1.1 or 1.2", key, value)
+			}
+			settings.MinVersion = value
+		case "cipher-suites":
+			settings.CipherSuites = nil
+			for _, name := range strings.Split(value, ":") {
+				if _, ok := tlsCipherSuites[name]; !ok {
+					return nil, fmt.Errorf("invalid registry TLS setting %s: unsupported cipher suite %q", key, name)
+				}
+				settings.CipherSuites = append(settings.CipherSuites, name)
+			}
+		case "server-name":
+			if value == "" {
+				return nil, fmt.Errorf("invalid registry TLS setting %s: empty server name", key)
+			}
+			settings.ServerName = value
+		case "insecure-skip-verify":
+			skip, err := strconv.ParseBool(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid registry TLS setting %s: %v", key, err)
+			}
+			settings.InsecureSkipVerify = skip
+		default:
+			return nil, fmt.Errorf("unknown registry TLS setting %q", key)
+		}
+	}
+	return settings, nil
+}
+
+// ValidateRegistryTLS validates a host=settings pair of the --registry-tls
+// flag.
+func ValidateRegistryTLS(val string) (string, error) {
+	parts := strings.SplitN(val, "=", 2)
+	if len(parts) != 2 || parts[0] == "" {
+		return "", fmt.Errorf("invalid registry TLS settings %q, expected host=key=value,...", val)
+	}
+	if _, err := ParseTLSSettings(parts[1]); err != nil {
+		return "", err
+	}
+	return val, nil
+}
+
+// ValidateTLSSettings validates the TLS settings of the registries. The
+// settings of the configuration file do not go through the validation of the
+// --registry-tls flag, so they are validated when the file is loaded.
+func ValidateTLSSettings(config map[string]string) error {
+	for host, val := range config {
+		if normalizeCredentialHelperHost(host) == "" {
+			return fmt.Errorf("invalid registry TLS settings %q, expected host=key=value,...", val)
+		}
+		if _, err := ParseTLSSettings(val); err != nil {
+			return fmt.Errorf("%s: %v", host, err)
+		}
+	}
+	return nil
+}
+
+// applyTLSSettings applies the TLS settings configured for a registry host,
+// if any, to tlsConfig. Skipping the verification of the certificates does
+// not make the registry insecure, so that the daemon never falls back to
+// plain HTTP for it.
+func (config *serviceConfig) applyTLSSettings(tlsConfig *tls.Config, hostname string) {
+	settings, ok := config.TLSConfigs[normalizeCredentialHelperHost(hostname)]
+	if !ok {
+		return
+	}
+	if settings.MinVersion != "" {
+		tlsConfig.MinVersion = tlsVersions[settings.MinVersion]
+	}
+	if len(settings.CipherSuites) > 0 {
+		tlsConfig.CipherSuites = make([]uint16, 0, len(settings.CipherSuites))
+		for _, name := range settings.CipherSuites {
+			tlsConfig.CipherSuites = append(tlsConfig.CipherSuites, tlsCipherSuites[name])
+		}
+	}
+	if settings.ServerName != "" {
+		tlsConfig.ServerName = settings.ServerName
+	}
+	if settings.InsecureSkipVerify {
+		tlsConfig.InsecureSkipVerify = true
+	}
+}
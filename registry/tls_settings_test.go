@@ -0,0 +1,105 @@
+package registry
+
+import (
+	"crypto/tls"
+	"reflect"
+	"testing"
+
+	registrytypes "github.com/docker/engine-api/types/registry"
+)
+
+func TestParseTLSSettings(t *testing.T) {
+	settings, err := ParseTLSSettings("min-version=1.2,cipher-suites=TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256:TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384, server-name=registry.internal,insecure-skip-verify=true")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &registrytypes.TLSConfig{
+		MinVersion:         "1.2",
+		CipherSuites:       []string{"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256", "TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384"},
+		ServerName:         "registry.internal",
+		InsecureSkipVerify: true,
+	}
+	if !reflect.DeepEqual(settings, expected) {
+		t.Fatalf("expected %+v, got %+v", expected, settings)
+	}
+
+	for _, val := range []string{
+		"",
+		"min-version",
+		"min-version=1.3",
+		"cipher-suites=TLS_RSA_WITH_RC4_128_SHA",
+		"server-name=",
+		"insecure-skip-verify=maybe",
+		"max-version=1.2",
+	} {
+		if _, err := ParseTLSSettings(val); err == nil {
+			t.Fatalf("expected %q to be rejected", val)
+		}
+	}
+}
+
+func TestValidateTLSSettings(t *testing.T) {
+	if _, err := ValidateRegistryTLS("registry.example.com:5000=min-version=1.2"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ValidateRegistryTLS("min-version=1.2"); err == nil {
+		t.Fatal("expected settings without a host to be rejected")
+	}
+	if err := ValidateTLSSettings(map[string]string{"registry.example.com:5000": "min-version=1.2"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ValidateTLSSettings(map[string]string{"registry.example.com:5000": "min-version=2"}); err == nil {
+		t.Fatal("expected invalid settings to be rejected")
+	}
+}
+
+func TestTLSConfigSettings(t *testing.T) {
+	s := NewService(ServiceOptions{RegistryTLS: map[string]string{
+		"https://registry.example.com:5000": "min-version=1.2,cipher-suites=TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,server-name=registry.internal,insecure-skip-verify=true",
+		"registry-1.docker.io":              "min-version=1.1",
+		"invalid.example.com":               "min-version=2",
+	}})
+
+	if _, ok := s.ServiceConfig().TLSConfigs["invalid.example.com"]; ok {
+		t.Fatal("expected invalid settings to be ignored")
+	}
+
+	tlsConfig, err := s.TLSConfig("registry.example.com:5000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tlsConfig.MinVersion != tls.VersionTLS12 || tlsConfig.ServerName != "registry.internal" || !tlsConfig.InsecureSkipVerify {
+		t.Fatalf("unexpected TLS configuration %+v", tlsConfig)
+	}
+	if !reflect.DeepEqual(tlsConfig.CipherSuites, []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256}) {
+		t.Fatalf("unexpected cipher suites %v", tlsConfig.CipherSuites)
+	}
+
+	endpoints, err := s.LookupPullEndpoints("registry.example.com:5000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, endpoint := range endpoints {
+		if endpoint.URL.Scheme != "https" {
+			t.Fatalf("expected skipping the verification not to enable plain HTTP, got %s", endpoint.URL)
+		}
+	}
+
+	endpoints, err = s.LookupPullEndpoints(IndexName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, endpoint := range endpoints {
+		if endpoint.TLSConfig.MinVersion != tls.VersionTLS11 {
+			t.Fatalf("expected the settings of Docker Hub to apply to %s", endpoint.URL)
+		}
+	}
+
+	tlsConfig, err = s.TLSConfig("other.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tlsConfig.ServerName != "" || tlsConfig.InsecureSkipVerify {
+		t.Fatalf("expected the settings not to apply to other registries, got %+v", tlsConfig)
+	}
+}